@@ -0,0 +1,161 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/viper"
+)
+
+// aliasTagSetting reads the alias_tags setting, which can only have major and minor, each at most once.
+func aliasTagSetting() ([]string, error) {
+	aliases := viper.GetStringSlice("alias_tags")
+	for i, alias := range aliases {
+		if alias != "major" && alias != "minor" {
+			return nil, fmt.Errorf("alias_tags has %s, but it can only have major and minor", alias)
+		}
+		if slices.Contains(aliases[:i], alias) {
+			return nil, fmt.Errorf("alias_tags has %s more than once", alias)
+		}
+	}
+	return aliases, nil
+}
+
+// aliasTagNames works out the names of the alias tags that follow a version, from the alias_tags setting,
+// like v1 and v1.4 for v1.4.2.
+//
+// Prereleases have no alias tags, and an alias tag is left where it is when a greater version on its line
+// is already tagged, like v1 when v1.5.0 is tagged before v1.4.3 is released.
+// As the names have fewer than three numbers, they are never read as versions.
+func aliasTagNames(prefix, version string) ([]string, error) {
+	aliases, err := aliasTagSetting()
+	if err != nil || len(aliases) == 0 {
+		return nil, err
+	}
+
+	pv := semver.ParseVersion(version)
+	if pv == nil {
+		return nil, fmt.Errorf("%s is not a valid version", version)
+	}
+	data := newVersionData(pv)
+	if data.Prerelease != "" {
+		return nil, nil
+	}
+
+	// Every tag with the prefix counts, not only those of the release line being released from.
+	tags, err := retrievePrefixedTags(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, alias := range aliases {
+		name := fmt.Sprint(data.Major)
+		if alias == "minor" {
+			name = fmt.Sprintf("%d.%d", data.Major, data.Minor)
+		}
+
+		if greater := greaterOnLine(tags, pv, alias == "minor"); greater != "" {
+			slog.Info(fmt.Sprintf("Leaving tag %s%s where it is, as %s%s is a greater version",
+				prefix, normalizeVersion(name), prefix, greater))
+			continue
+		}
+		names = append(names, prefix+normalizeVersion(name))
+	}
+
+	return names, nil
+}
+
+// greaterOnLine finds a tag of a release greater than pv with the same major version,
+// and the same minor version too if minor is set.
+//
+// It returns "" if there is none.
+func greaterOnLine(tags map[string]plumbing.Hash, pv *semver.ParsedVersion, minor bool) string {
+	data := newVersionData(pv)
+	for name := range tags {
+		pvTag := semver.ParseVersion(name)
+		if pvTag == nil {
+			continue
+		}
+
+		tagData := newVersionData(pvTag)
+		if tagData.Prerelease != "" || tagData.Major != data.Major || (minor && tagData.Minor != data.Minor) {
+			continue
+		}
+		if semver.ParsedVersionSlice([]*semver.ParsedVersion{pv, pvTag}).Less(0, 1) {
+			return name
+		}
+	}
+	return ""
+}
+
+// moveAliasTags points alias tags at a commit, and force-pushes only them to every remote.
+func moveAliasTags(names []string, head plumbing.Hash) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	refSpecs := make([]config.RefSpec, 0, len(names))
+	for _, name := range names {
+		err := repo.DeleteTag(name)
+		if err != nil && !errors.Is(err, git.ErrTagNotFound) {
+			return err
+		}
+
+		_, err = repo.CreateTag(name, head, nil)
+		if err != nil {
+			return err
+		}
+		slog.Info(fmt.Sprintf("Moved tag %s to revision %s", name, head.String()))
+
+		ref := plumbing.NewTagReferenceName(name)
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)))
+	}
+
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return err
+	}
+
+	for _, remote := range remotes {
+		slog.Debug(fmt.Sprintf("Pushing tags %v to %s", names, remote.Config().Name))
+		err = remote.Push(&git.PushOptions{
+			RemoteName: remote.Config().Name,
+			RefSpecs:   refSpecs,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return err
+		}
+	}
+
+	return nil
+}
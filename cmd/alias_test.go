@@ -0,0 +1,115 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestAliasTagNames(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	for _, name := range []string{"v1.4.2", "v1.5.0", "v2.0.0-rc.1", "lib/v2.3.0"} {
+		_, err := repo.CreateTag(name, commit, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() {
+		viper.Set("alias_tags", nil)
+		viper.Set("initial_v", nil)
+	})
+	viper.Set("initial_v", true)
+
+	viper.Set("alias_tags", []string{"major", "patch"})
+	_, err := aliasTagNames("", "v1.6.0")
+	if err == nil {
+		t.Error(ExpectError, "for a patch alias")
+	}
+	_, err = aliasTagNames("", "v1.6.0-rc.1")
+	if err == nil {
+		t.Error(ExpectError, "for a patch alias of a prerelease")
+	}
+	viper.Set("alias_tags", []string{"minor", "minor"})
+	_, err = aliasTagNames("", "v1.6.0")
+	if err == nil {
+		t.Error(ExpectError, "for an alias given twice")
+	}
+
+	viper.Set("alias_tags", []string{"major", "minor"})
+	tests := []struct {
+		prefix   string
+		version  string
+		expected []string
+	}{
+		{"", "v1.6.0", []string{"v1", "v1.6"}},
+		{"", "v1.4.3", []string{"v1.4"}},
+		{"", "v1.6.0-rc.1", nil},
+		{"", "v2.0.0", []string{"v2", "v2.0"}},
+		{"lib/", "v2.2.1", []string{"lib/v2.2"}},
+	}
+	for _, test := range tests {
+		names, err := aliasTagNames(test.prefix, test.version)
+		if err != nil {
+			t.Error(test.version, ExpectNilError, err)
+		}
+		if diff := cmp.Diff(test.expected, names); diff != "" {
+			t.Errorf("incorrect result for %s%s: %s", test.prefix, test.version, diff)
+		}
+	}
+}
+
+func TestReleaseChecksAliasTags(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	_, err := repo.CreateTag("v1.0.0", commit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCommit(t, worktree, "fix: thing", map[string]string{"thing.go": "package m\n"})
+
+	t.Cleanup(func() { viper.Set("alias_tags", nil) })
+	viper.Set("alias_tags", []string{"patch"})
+
+	for _, all := range []bool{false, true} {
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("patch", true, "")
+		cmd.Flags().Bool("all-modules", all, "")
+		cmd.Flags().Bool("dry-run", true, "")
+		cmd.Flags().String("set-version", "", "")
+
+		// The setting is checked before the release notes or the confirmation.
+		err = release(cmd, semver.NonSegment)
+		if err == nil || !strings.Contains(err.Error(), "alias_tags") {
+			t.Error(ExpectError, "about alias_tags for a patch alias with --all-modules", all, "got", err)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/exp/apidiff"
+	"golang.org/x/mod/modfile"
+)
+
+// treeFileInfo describes a tree entry for go/build.
+type treeFileInfo struct {
+	entry object.TreeEntry
+	size  int64
+}
+
+func (fi treeFileInfo) Name() string       { return fi.entry.Name }
+func (fi treeFileInfo) Size() int64        { return fi.size }
+func (fi treeFileInfo) Mode() fs.FileMode  { mode, _ := fi.entry.Mode.ToOSFileMode(); return mode }
+func (fi treeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi treeFileInfo) IsDir() bool        { return fi.entry.Mode == filemode.Dir }
+func (fi treeFileInfo) Sys() any           { return nil }
+
+// treeImporter type-checks the packages of a Go module straight from a commit,
+// so the working tree does not have to be checked out at it.
+//
+// Packages from outside the module are imported from their compiled export data,
+// or left empty if there is none, which makes the types from them compare as invalid on both sides.
+type treeImporter struct {
+	tree     *object.Tree
+	modPath  string
+	fset     *token.FileSet
+	ctxt     build.Context
+	packages map[string]*types.Package
+	loading  map[string]bool
+	fallback types.Importer
+}
+
+// newTreeImporter makes a treeImporter for the module in a directory of a commit.
+func newTreeImporter(commit plumbing.Hash, modDir string) (*treeImporter, error) {
+	c, err := repo.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if modDir != "." {
+		tree, err = tree.Tree(modDir)
+		if err != nil {
+			return nil, fmt.Errorf("Could not find directory %s in commit %s: %w", modDir, shortHash(commit), err)
+		}
+	}
+
+	file, err := tree.File("go.mod")
+	if err != nil {
+		return nil, fmt.Errorf("Could not find go.mod in commit %s: %w", shortHash(commit), err)
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	modPath := modfile.ModulePath([]byte(contents))
+	if modPath == "" {
+		return nil, fmt.Errorf("The go.mod in commit %s has no module path", shortHash(commit))
+	}
+
+	ti := &treeImporter{
+		tree:     tree,
+		modPath:  modPath,
+		fset:     token.NewFileSet(),
+		packages: make(map[string]*types.Package),
+		loading:  make(map[string]bool),
+	}
+	ti.fallback = importer.ForCompiler(ti.fset, "gc", nil)
+
+	// Paths are rooted at /, which stands for the top of the module.
+	ti.ctxt = build.Default
+	ti.ctxt.GOROOT = ""
+	ti.ctxt.GOPATH = ""
+	ti.ctxt.CgoEnabled = false
+	ti.ctxt.JoinPath = path.Join
+	ti.ctxt.IsAbsPath = path.IsAbs
+	ti.ctxt.IsDir = func(dir string) bool {
+		_, err := ti.subtree(dir)
+		return err == nil
+	}
+	ti.ctxt.ReadDir = ti.readDir
+	ti.ctxt.OpenFile = func(name string) (io.ReadCloser, error) {
+		file, err := ti.tree.File(strings.TrimPrefix(name, "/"))
+		if err != nil {
+			return nil, err
+		}
+		return file.Reader()
+	}
+
+	return ti, nil
+}
+
+func (ti *treeImporter) subtree(dir string) (*object.Tree, error) {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return ti.tree, nil
+	}
+	return ti.tree.Tree(dir)
+}
+
+func (ti *treeImporter) readDir(dir string) ([]fs.FileInfo, error) {
+	tree, err := ti.subtree(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]fs.FileInfo, 0, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		info := treeFileInfo{entry: entry}
+		if entry.Mode.IsFile() {
+			info.size, _ = tree.Size(entry.Name)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Import type-checks a package of the module, or imports one from outside it.
+func (ti *treeImporter) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := ti.packages[importPath]; ok {
+		return pkg, nil
+	}
+
+	rel, inModule := strings.CutPrefix(importPath, ti.modPath)
+	if !inModule || (rel != "" && !strings.HasPrefix(rel, "/")) {
+		pkg, err := ti.fallback.Import(importPath)
+		if err != nil {
+			pkg = types.NewPackage(importPath, path.Base(importPath))
+			pkg.MarkComplete()
+		}
+		ti.packages[importPath] = pkg
+		return pkg, nil
+	}
+
+	pkg, err := ti.load("/" + strings.TrimPrefix(rel, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// load type-checks the package in a directory of the module, unless it already has been.
+func (ti *treeImporter) load(dir string) (*types.Package, error) {
+	importPath := ti.modPath
+	if dir != "/" {
+		importPath += dir
+	}
+
+	if pkg, ok := ti.packages[importPath]; ok {
+		return pkg, nil
+	}
+	if ti.loading[importPath] {
+		return nil, fmt.Errorf("Package %s is part of an import cycle", importPath)
+	}
+	ti.loading[importPath] = true
+	defer delete(ti.loading, importPath)
+
+	bp, err := ti.ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*ast.File, 0, len(bp.GoFiles))
+	for _, name := range bp.GoFiles {
+		reader, err := ti.ctxt.OpenFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(ti.fset, path.Join(dir, name), reader, parser.SkipObjectResolution)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	// Type errors are left in, as the API is what matters.
+	conf := types.Config{Importer: ti, Error: func(error) {}}
+	pkg, _ := conf.Check(importPath, ti.fset, files, nil)
+	ti.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// module type-checks every package of the module that other modules can import.
+func (ti *treeImporter) module() (*apidiff.Module, error) {
+	var (
+		dirs   = make(map[string]bool)
+		nested []string
+	)
+	err := ti.tree.Files().ForEach(func(file *object.File) error {
+		dir := path.Dir(file.Name)
+		switch {
+		case path.Base(file.Name) == "go.mod" && dir != ".":
+			nested = append(nested, dir)
+		case strings.HasSuffix(file.Name, ".go") && !strings.HasSuffix(file.Name, "_test.go"):
+			dirs[dir] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	module := &apidiff.Module{Path: ti.modPath}
+	for dir := range dirs {
+		if !importable(dir) || slices.ContainsFunc(nested, func(nestedDir string) bool { return inDir(dir, nestedDir) }) {
+			continue
+		}
+
+		pkg, err := ti.load(path.Join("/", dir))
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pkg.Name() != "main" {
+			module.Packages = append(module.Packages, pkg)
+		}
+	}
+
+	return module, nil
+}
+
+// importable checks whether other modules can import the package in a directory of a module.
+func importable(dir string) bool {
+	if dir == "." {
+		return true
+	}
+
+	for _, part := range strings.Split(dir, "/") {
+		if part == "internal" || part == "vendor" || part == "testdata" ||
+			strings.HasPrefix(part, ".") || strings.HasPrefix(part, "_") {
+			return false
+		}
+	}
+	return true
+}
+
+// apiChanges compares the API of the module in a directory between two commits.
+func apiChanges(from, to plumbing.Hash, modDir string) (apidiff.Report, error) {
+	modules := make([]*apidiff.Module, 0, 2)
+	for _, commit := range []plumbing.Hash{from, to} {
+		ti, err := newTreeImporter(commit, modDir)
+		if err != nil {
+			return apidiff.Report{}, err
+		}
+		module, err := ti.module()
+		if err != nil {
+			return apidiff.Report{}, fmt.Errorf("Could not load the packages in commit %s: %w", shortHash(commit), err)
+		}
+		modules = append(modules, module)
+	}
+
+	return apidiff.ModuleChanges(modules[0], modules[1]), nil
+}
+
+// apiIncrement works out the increment API changes call for.
+//
+// Incompatible changes call for a major version, or a minor one before 1.0.0;
+// compatible ones call for a minor version.
+func apiIncrement(report apidiff.Report, pvCurrent *semver.ParsedVersion) semver.VersionSegment {
+	if len(report.Changes) == 0 {
+		return semver.Patch
+	}
+
+	for _, change := range report.Changes {
+		if !change.Compatible && newVersionData(pvCurrent).Major != 0 {
+			return semver.Major
+		}
+	}
+	return semver.Minor
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"golang.org/x/exp/apidiff"
+)
+
+func TestApiIncrement(t *testing.T) {
+	compatible := apidiff.Change{Message: "A: added", Compatible: true}
+	incompatible := apidiff.Change{Message: "B: removed", Compatible: false}
+
+	tests := []struct {
+		changes  []apidiff.Change
+		version  string
+		expected semver.VersionSegment
+	}{
+		{nil, "1.2.3", semver.Patch},
+		{[]apidiff.Change{compatible}, "1.2.3", semver.Minor},
+		{[]apidiff.Change{compatible, incompatible}, "1.2.3", semver.Major},
+		{[]apidiff.Change{incompatible}, "0.2.3", semver.Minor},
+	}
+
+	for _, test := range tests {
+		report := apidiff.Report{Changes: test.changes}
+		if got := apiIncrement(report, semver.ParseVersion(test.version)); got != test.expected {
+			t.Errorf("incorrect result for %v since %s: expected %s, got %s", test.changes, test.version,
+				test.expected, got)
+		}
+	}
+}
+
+func TestTreeImporterModule(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod":              "module example.com/m\n",
+		"m.go":                "package m\n\nimport _ \"example.com/m/a\"\n\nfunc M() {}\n",
+		"a/a.go":              "package a\n\nimport _ \"example.com/m/b\"\n\nfunc A() {}\n",
+		"b/b.go":              "package b\n\nimport _ \"example.com/m/a\"\n\nfunc B() {}\n",
+		"internal/i/i.go":     "package i\n",
+		"cmd/tool/main.go":    "package main\n\nfunc main() {}\n",
+		"nested/go.mod":       "module example.com/m/nested\n",
+		"nested/nested.go":    "package nested\n",
+		"a/testdata/bad/x.go": "package x\n",
+	})
+	commit := testCommit(t, worktree, "chore: init", nil)
+
+	ti, err := newTreeImporter(commit, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, err := ti.module()
+	if err != nil {
+		t.Fatal(ExpectNilError, err)
+	}
+
+	found := make(map[string]int)
+	for _, pkg := range module.Packages {
+		found[pkg.Path()]++
+	}
+	//revive:disable-next-line:add-constant
+	if len(module.Packages) != 3 || found["example.com/m"] != 1 || found["example.com/m/a"] != 1 ||
+		found["example.com/m/b"] != 1 {
+		t.Error("incorrect result: expected each of the importable packages once, got", found)
+	}
+}
+
+func TestApiChanges(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"m.go":   "package m\n\nfunc A() {}\n",
+	})
+	first := testCommit(t, worktree, "chore: init", nil)
+	added := testCommit(t, worktree, "feat: B", map[string]string{"m.go": "package m\n\nfunc A() {}\n\nfunc B() {}\n"})
+	removed := testCommit(t, worktree, "feat!: no A", map[string]string{"m.go": "package m\n\nfunc B() {}\n"})
+
+	report, err := apiChanges(first, added, ".")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if got := apiIncrement(report, semver.ParseVersion("1.0.0")); got != semver.Minor {
+		t.Error("incorrect result: expected a minor version for an added function, got", got)
+	}
+
+	report, err = apiChanges(added, removed, ".")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if got := apiIncrement(report, semver.ParseVersion("1.0.0")); got != semver.Major {
+		t.Error("incorrect result: expected a major version for a removed function, got", got)
+	}
+}
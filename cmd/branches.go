@@ -0,0 +1,368 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/viper"
+)
+
+// branchLine is an entry of the branches setting.
+type branchLine struct {
+	// Name is a glob of branch names, like release/*.
+	Name string `mapstructure:"name"`
+	// Line is major or minor, for branches whose names end in a version line like 1 or 1.3.
+	Line string `mapstructure:"line"`
+}
+
+// releaseLine is the version line the current branch releases, like 1.3.
+type releaseLine struct {
+	branch string
+	major  int
+	// minor is -1 for a major version line.
+	minor int
+}
+
+var lineRegexp = regexp.MustCompile(`\Av?(\d+)(?:[.](\d+))?\z`)
+
+// currentReleaseLine finds the version line of the current branch from the branches setting.
+//
+// It returns nil if the current branch is not a maintenance branch.
+func currentReleaseLine() (*releaseLine, error) {
+	lines, err := branchLines()
+	if err != nil || len(lines) == 0 {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if !head.Name().IsBranch() {
+		return nil, nil //nolint:nilnil // A detached HEAD is on no branch.
+	}
+
+	return branchReleaseLine(head.Name().Short(), lines)
+}
+
+// branchLines reads the branches setting.
+func branchLines() ([]branchLine, error) {
+	var lines []branchLine
+	err := viper.UnmarshalKey("branches", &lines)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read branches: %w", err)
+	}
+	return lines, nil
+}
+
+// branchReleaseLine finds the version line of a branch from the entries of the branches setting.
+//
+// It returns nil if the branch is not a maintenance branch.
+func branchReleaseLine(branch string, lines []branchLine) (*releaseLine, error) {
+	for _, line := range lines {
+		if line.Line != "major" && line.Line != "minor" {
+			return nil, fmt.Errorf("The branches entry for %s has line %s, but it can only be major or minor",
+				line.Name, line.Line)
+		}
+		matched, err := doublestar.Match(line.Name, branch)
+		if err != nil {
+			return nil, fmt.Errorf("%s in branches is not a valid glob", line.Name)
+		}
+		if !matched {
+			continue
+		}
+
+		matches := lineRegexp.FindStringSubmatch(path.Base(branch))
+		if matches == nil || (line.Line == "minor") != (matches[2] != "") {
+			return nil, fmt.Errorf("Branch %s does not end in a %s version line", branch, line.Line)
+		}
+
+		rl := &releaseLine{branch: branch, minor: -1}
+		rl.major, _ = strconv.Atoi(matches[1])
+		if matches[2] != "" {
+			rl.minor, _ = strconv.Atoi(matches[2])
+		}
+		return rl, nil
+	}
+
+	return nil, nil //nolint:nilnil // Branches not in the setting are not maintenance branches.
+}
+
+func (rl *releaseLine) String() string {
+	if rl.minor < 0 {
+		return strconv.Itoa(rl.major)
+	}
+	return fmt.Sprintf("%d.%d", rl.major, rl.minor)
+}
+
+// contains checks whether a version is on the line.
+func (rl *releaseLine) contains(pv *semver.ParsedVersion) bool {
+	data := newVersionData(pv)
+	return data.Major == rl.major && (rl.minor < 0 || data.Minor == rl.minor)
+}
+
+// releaseLineTags leaves out the tags the current branch does not release from:
+// those not on its version line, when it is a maintenance branch in the branches setting,
+// and those not reachable from HEAD, when reachable_tags_only is set.
+func releaseLineTags(tags map[string]plumbing.Hash) (map[string]plumbing.Hash, *releaseLine, error) {
+	rl, err := currentReleaseLine()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reachable map[plumbing.Hash]bool
+	if viper.GetBool("reachable_tags_only") {
+		reachable, err = reachableFromHead()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	filtered := make(map[string]plumbing.Hash, len(tags))
+	for name, hash := range tags {
+		if rl != nil {
+			pv := semver.ParseVersion(name)
+			if pv == nil || !rl.contains(pv) {
+				continue
+			}
+		}
+		if reachable != nil && !reachable[hash] {
+			continue
+		}
+		filtered[name] = hash
+	}
+
+	if rl != nil {
+		// The first version on a line is released from the main branch.
+		if len(filtered) == 0 {
+			return nil, nil, fmt.Errorf("There are no tags on the %s line for branch %s to continue from", rl, rl.branch)
+		}
+		slog.Debug(fmt.Sprintf("Branch %s releases the %s line", rl.branch, rl))
+	}
+	return filtered, rl, nil
+}
+
+// reachableFromHead finds every commit reachable from HEAD.
+func reachableFromHead() (map[plumbing.Hash]bool, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[plumbing.Hash]bool)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		reachable[commit.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reachable, nil
+}
+
+// checkReleaseLine makes sure the next version stays on the version line of the current branch.
+func checkReleaseLine(rl *releaseLine, pvNext *semver.ParsedVersion) error {
+	if rl == nil || rl.contains(pvNext) {
+		return nil
+	}
+
+	return fmt.Errorf("Version %s is not on the %s line that branch %s releases",
+		normalizeVersion(pvNext.String()), rl, rl.branch)
+}
+
+// releaseBranchName works out the maintenance branch to create for a release, from the create_release_branch template.
+// In module mode, the name starts with the tag prefix, like semver/release/1.3, so each module has its own.
+//
+// It returns "" if none is wanted: the setting is not set, the release is a patch or a prerelease,
+// or it is not made from a branch releases are made from, which maintenance branches never are.
+// The name is checked even then, so a broken template is found before anything is tagged.
+func releaseBranchName(pvNext *semver.ParsedVersion, rl *releaseLine) (string, error) {
+	format := viper.GetString("create_release_branch")
+	if format == "" || rl != nil {
+		return "", nil
+	}
+
+	data := newVersionData(pvNext)
+	tmpl, err := template.New("create_release_branch").Option("missingkey=error").Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse create_release_branch: %w", err)
+	}
+
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, data)
+	if err != nil {
+		return "", fmt.Errorf("Could not work out the release branch name: %w", err)
+	}
+
+	name := tagPrefix() + sb.String()
+	if plumbing.NewBranchReferenceName(name).Validate() != nil {
+		return "", fmt.Errorf("The release branch name %q from create_release_branch is not a valid branch name", name)
+	}
+
+	// Releases from the branch only keep to its version line if it is in the branches setting.
+	lines, err := branchLines()
+	if err != nil {
+		return "", err
+	}
+	line, err := branchReleaseLine(name, lines)
+	if err != nil {
+		return "", err
+	}
+
+	if data.Patch != 0 || data.Prerelease != "" {
+		return "", nil
+	}
+
+	source, err := onReleaseSourceBranch()
+	if err != nil || !source {
+		return "", err
+	}
+
+	if line == nil {
+		slog.Warn(fmt.Sprintf("Release branch %s matches no entry of the branches setting, "+
+			"so releases from it will not keep to its version line", name))
+	}
+	return name, nil
+}
+
+// onReleaseSourceBranch checks whether HEAD is on a branch that release branches are created from:
+// one that matches a glob in the release_branch_from setting, or else the default branch.
+func onReleaseSourceBranch() (bool, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return false, err
+	}
+	if !head.Name().IsBranch() {
+		slog.Info("Not creating a release branch, as HEAD is not on a branch")
+		return false, nil
+	}
+	branch := head.Name().Short()
+
+	sources := viper.GetStringSlice("release_branch_from")
+	if len(sources) == 0 {
+		defaultName, err := defaultBranch()
+		if err != nil {
+			return false, err
+		}
+		sources = []string{defaultName}
+	}
+
+	for _, source := range sources {
+		matched, err := doublestar.Match(source, branch)
+		if err != nil {
+			return false, fmt.Errorf("%s in release_branch_from is not a valid glob", source)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Not creating a release branch, as branch %s is not one releases are made from", branch))
+	return false, nil
+}
+
+// defaultBranch finds the default branch from the HEAD of a remote, or else whichever of main and master exists.
+//
+// It returns "" if there is none.
+func defaultBranch() (string, error) {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return "", err
+	}
+
+	for _, remote := range remotes {
+		name := remote.Config().Name
+		ref, err := repo.Reference(plumbing.NewRemoteHEADReferenceName(name), false)
+		if err == nil && ref.Type() == plumbing.SymbolicReference {
+			return strings.TrimPrefix(ref.Target().Short(), name+"/"), nil
+		}
+	}
+
+	for _, branch := range []string{"main", "master"} {
+		_, err := repo.Reference(plumbing.NewBranchReferenceName(branch), false)
+		if err == nil {
+			return branch, nil
+		}
+	}
+
+	return "", nil
+}
+
+// createReleaseBranch creates a branch at a commit, and pushes only it to every remote.
+//
+// A branch that already exists is left alone.
+func createReleaseBranch(name string, head plumbing.Hash) error {
+	ref := plumbing.NewBranchReferenceName(name)
+	_, err := repo.Reference(ref, false)
+	if err == nil {
+		slog.Info(fmt.Sprintf("Branch %s already exists", name))
+		return nil
+	}
+	if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return err
+	}
+
+	err = repo.Storer.SetReference(plumbing.NewHashReference(ref, head))
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Created branch %s at revision %s", name, head.String()))
+
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return err
+	}
+
+	for _, remote := range remotes {
+		slog.Debug(fmt.Sprintf("Pushing branch %s to %s", name, remote.Config().Name))
+		err = remote.Push(&git.PushOptions{
+			RemoteName: remote.Config().Name,
+			RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return err
+		}
+	}
+
+	return nil
+}
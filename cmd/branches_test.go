@@ -0,0 +1,221 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/viper"
+)
+
+func TestCurrentReleaseLine(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	t.Cleanup(func() { viper.Set("branches", nil) })
+
+	minorLines := []map[string]string{{"name": "release/*", "line": "minor"}}
+	majorLines := []map[string]string{{"name": "support/*", "line": "major"}}
+	tests := []struct {
+		name      string
+		branches  []map[string]string
+		branch    string
+		expected  string
+		expectErr bool
+	}{
+		{"no setting", nil, "release/v1.3", "", false},
+		{"minor line", minorLines, "release/v1.3", "1.3", false},
+		{"minor line without v", minorLines, "release/1.3", "1.3", false},
+		{"other branch", minorLines, "main", "", false},
+		{"major line", majorLines, "support/1", "1", false},
+		{"major line on minor branch", majorLines, "support/1.3", "", true},
+		{"minor line on major branch", []map[string]string{{"name": "support/*", "line": "minor"}}, "support/1",
+			"", true},
+		{"unknown line", []map[string]string{{"name": "release/*", "line": "patch"}}, "release/v1.3", "", true},
+	}
+
+	for _, test := range tests {
+		viper.Set("branches", test.branches)
+		testCheckout(t, test.branch, commit)
+
+		rl, err := currentReleaseLine()
+		if test.expectErr {
+			if err == nil {
+				t.Error(test.name, ExpectError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.name, ExpectNilError, err)
+			continue
+		}
+
+		got := ""
+		if rl != nil {
+			got = rl.String()
+		}
+		if got != test.expected {
+			t.Errorf("incorrect result for %s: expected line %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestReleaseLineTags(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	first := testCommit(t, worktree, "chore: init", nil)
+	second := testCommit(t, worktree, "fix: thing", map[string]string{"thing.go": "package m\n"})
+	elsewhere := testCommit(t, worktree, "fix: elsewhere", map[string]string{"other.go": "package m\n"})
+	testCheckout(t, "main", second)
+
+	tags := map[string]plumbing.Hash{
+		"v1.2.0": first,
+		"v1.3.0": first,
+		"v1.3.1": second,
+		"v1.4.0": elsewhere,
+	}
+	t.Cleanup(func() {
+		viper.Set("branches", nil)
+		viper.Set("reachable_tags_only", nil)
+	})
+
+	tests := []struct {
+		name      string
+		branches  []map[string]string
+		reachable bool
+		branch    string
+		expected  []string
+		expectErr bool
+	}{
+		{"all tags", nil, false, "main", []string{"v1.2.0", "v1.3.0", "v1.3.1", "v1.4.0"}, false},
+		{"reachable only", nil, true, "main", []string{"v1.2.0", "v1.3.0", "v1.3.1"}, false},
+		{"minor line", []map[string]string{{"name": "release/*", "line": "minor"}}, false, "release/v1.3",
+			[]string{"v1.3.0", "v1.3.1"}, false},
+		{"major line reachable only", []map[string]string{{"name": "support/*", "line": "major"}}, true, "support/1",
+			[]string{"v1.2.0", "v1.3.0", "v1.3.1"}, false},
+		{"line without tags", []map[string]string{{"name": "release/*", "line": "minor"}}, false, "release/1.9",
+			nil, true},
+	}
+
+	for _, test := range tests {
+		viper.Set("branches", test.branches)
+		viper.Set("reachable_tags_only", test.reachable)
+		testCheckout(t, test.branch, second)
+
+		filtered, _, err := releaseLineTags(tags)
+		if test.expectErr {
+			if err == nil {
+				t.Error(test.name, ExpectError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.name, ExpectNilError, err)
+			continue
+		}
+
+		got := make([]string, 0, len(filtered))
+		for name := range filtered {
+			got = append(got, name)
+		}
+		slices.Sort(got)
+		if diff := cmp.Diff(test.expected, got); diff != "" {
+			t.Errorf("incorrect result for %s: %s", test.name, diff)
+		}
+	}
+}
+
+func TestReleaseBranchName(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	t.Cleanup(func() {
+		for _, key := range []string{"create_release_branch", "release_branch_from", "branches", "module"} {
+			viper.Set(key, nil)
+		}
+	})
+
+	const format = "release/{{.Major}}.{{.Minor}}"
+	tests := []struct {
+		name      string
+		format    string
+		from      []string
+		module    string
+		branch    string
+		version   string
+		rl        *releaseLine
+		expected  string
+		expectErr bool
+	}{
+		{"no setting", "", nil, "", "main", "1.3.0", nil, "", false},
+		{"minor release", format, nil, "", "main", "1.3.0", nil, "release/1.3", false},
+		{"major release", format, nil, "", "main", "2.0.0", nil, "release/2.0", false},
+		{"patch release", format, nil, "", "main", "1.3.1", nil, "", false},
+		{"prerelease", format, nil, "", "main", "1.3.0-rc.1", nil, "", false},
+		{"maintenance branch", format, nil, "", "release/1.2", "1.3.0", &releaseLine{branch: "release/1.2"}, "",
+			false},
+		{"feature branch", format, nil, "", "feature/thing", "1.3.0", nil, "", false},
+		{"source branch", format, []string{"develop", "next/*"}, "", "next/big", "1.3.0", nil, "release/1.3", false},
+		{"module", format, nil, "semver", "main", "1.3.0", nil, "semver/release/1.3", false},
+		{"space", "release {{.Major}}", nil, "", "main", "1.3.0", nil, "", true},
+		{"dots", "release/..{{.Major}}", nil, "", "main", "1.3.0", nil, "", true},
+		{"empty", "{{if false}}release{{end}}", nil, "", "main", "1.3.0", nil, "", true},
+		{"missing field", "release/{{.Nope}}", nil, "", "main", "1.3.0", nil, "", true},
+		{"invalid on a patch release", "release {{.Major}}", nil, "", "main", "1.3.1", nil, "", true},
+	}
+
+	for _, test := range tests {
+		viper.Set("create_release_branch", test.format)
+		viper.Set("release_branch_from", test.from)
+		viper.Set("module", test.module)
+		testCheckout(t, test.branch, commit)
+
+		name, err := releaseBranchName(semver.ParseVersion(test.version), test.rl)
+		if test.expectErr {
+			if err == nil {
+				t.Error(test.name, ExpectError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.name, ExpectNilError, err)
+		}
+		if name != test.expected {
+			t.Errorf("incorrect result for %s: expected %q, got %q", test.name, test.expected, name)
+		}
+	}
+
+	viper.Set("create_release_branch", format)
+	viper.Set("release_branch_from", nil)
+	viper.Set("module", nil)
+	err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, commit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := releaseBranchName(semver.ParseVersion("1.3.0"), nil)
+	if err != nil || name != "" {
+		t.Error("incorrect result: expected no branch from a detached HEAD, got", name, err)
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/viper"
+)
+
+const (
+	// changelogMarker is the line in the changelog that new release notes are inserted after.
+	changelogMarker = "<!-- git-next-tag release notes start -->"
+
+	// changelogPerm is the permission used when the changelog does not exist yet.
+	changelogPerm fs.FileMode = 0o644
+)
+
+// changelogType is a kind of changelog fragment, in the order the kinds are rendered.
+type changelogType struct {
+	Name  string `mapstructure:"name"`
+	Title string `mapstructure:"title"`
+}
+
+// defaultChangelogTypes are the fragment types used when changelog_types is not set.
+var defaultChangelogTypes = []changelogType{
+	{Name: "feature", Title: "Features"},
+	{Name: "bugfix", Title: "Bug Fixes"},
+	{Name: "doc", Title: "Documentation"},
+	{Name: "removal", Title: "Deprecations and Removals"},
+	{Name: "misc", Title: "Miscellaneous"},
+}
+
+// changelogFragment is a single file of release notes, such as changes/123.feature.md.
+type changelogFragment struct {
+	file string
+	id   string
+	kind string
+	text string
+}
+
+func changelogFragmentsDir() string {
+	dir := viper.GetString("changelog_fragments_dir")
+	if dir == "" {
+		dir = "changes"
+	}
+	return modulePath(dir)
+}
+
+func changelogFile() string {
+	file := viper.GetString("changelog_file")
+	if file == "" {
+		file = "CHANGELOG.md"
+	}
+	return modulePath(file)
+}
+
+func changelogTypes() ([]changelogType, error) {
+	if !viper.IsSet("changelog_types") {
+		return defaultChangelogTypes, nil
+	}
+
+	var types []changelogType
+	err := viper.UnmarshalKey("changelog_types", &types)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read changelog_types: %w", err)
+	}
+	return types, nil
+}
+
+// collectFragments reads the changelog fragments waiting to be released.
+//
+// A missing fragments directory means there is nothing to release.
+func collectFragments() ([]changelogFragment, error) {
+	types, err := changelogTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := changelogFragmentsDir()
+	entries, err := os.ReadDir(filepath.Join(gitDir, dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read changelog fragments directory %s: %w", dir, err)
+	}
+
+	fragments := make([]changelogFragment, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		// 123.feature.md and 123.feature are both accepted, and the id can have dots in it, like 1.2.feature.md.
+		base := strings.TrimSuffix(name, ".md")
+		dot := strings.LastIndex(base, ".")
+		if dot <= 0 {
+			slog.Debug(fmt.Sprintf("Skipping %s in %s, as it is not named <id>.<type>.md", name, dir))
+			continue
+		}
+		id, kind := base[:dot], base[dot+1:]
+		if !slices.ContainsFunc(types, func(ct changelogType) bool { return ct.Name == kind }) {
+			slog.Warn(fmt.Sprintf("Skipping %s in %s, as %s is not a changelog type", name, dir, kind))
+			continue
+		}
+
+		file := path.Join(dir, name)
+		text, err := os.ReadFile(filepath.Join(gitDir, file))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read changelog fragment %s: %w", file, err)
+		}
+
+		fragments = append(fragments, changelogFragment{
+			file: file,
+			id:   id,
+			kind: kind,
+			text: strings.TrimSpace(string(text)),
+		})
+	}
+
+	return fragments, nil
+}
+
+// renderReleaseNotes renders the fragments as a markdown changelog section, ordered by type,
+// followed by the commits since the previous version and who made them.
+//
+// References to issues and pull requests in either are turned into links.
+// Without any fragments or commits there are no release notes.
+func renderReleaseNotes(version string, fragments []changelogFragment, commits []*object.Commit) (string, error) {
+	commits = slices.DeleteFunc(slices.Clone(commits), func(commit *object.Commit) bool {
+		return commit.NumParents() > 1 || isAutosquash(commit.Message) ||
+			strings.HasPrefix(commit.Message, versionCommitPrefix)
+	})
+	if len(fragments) == 0 && len(commits) == 0 {
+		return "", nil
+	}
+
+	types, err := changelogTypes()
+	if err != nil {
+		return "", err
+	}
+
+	lnk, err := newLinker()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s (%s)\n", version, time.Now().Format(time.DateOnly))
+
+	for _, ct := range types {
+		var entries []string
+		for _, fragment := range fragments {
+			if fragment.kind != ct.Name {
+				continue
+			}
+
+			entry := "- " + strings.ReplaceAll(fragment.text, "\n", "\n  ")
+			// Fragments named like +something.feature.md have no issue to refer to.
+			if !strings.HasPrefix(fragment.id, "+") {
+				entry += " (#" + fragment.id + ")"
+			}
+
+			entry, err = lnk.link(entry)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, entry)
+		}
+
+		if len(entries) != 0 {
+			fmt.Fprintf(&sb, "\n### %s\n\n%s\n", ct.Title, strings.Join(entries, "\n"))
+		}
+	}
+
+	if len(commits) == 0 {
+		return sb.String(), nil
+	}
+
+	sb.WriteString("\n### Commits\n\n")
+	var contributors []string
+	for _, commit := range commits {
+		subject, body, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+
+		entry, err := lnk.link(subject)
+		if err != nil {
+			return "", err
+		}
+
+		// References in the body, like "Fixes #123", are listed after the commit.
+		refs, err := lnk.linkAll(body)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "- %s (%s)\n", entry, strings.Join(append([]string{shortHash(commit.Hash)}, refs...), ", "))
+
+		if !slices.Contains(contributors, commit.Author.Name) {
+			contributors = append(contributors, commit.Author.Name)
+		}
+	}
+
+	slices.Sort(contributors)
+	fmt.Fprintf(&sb, "\n### Contributors\n\n- %s\n", strings.Join(contributors, "\n- "))
+
+	return sb.String(), nil
+}
+
+// changelogStep writes the release notes into the changelog and removes the fragments.
+func changelogStep(notes string, fragments []changelogFragment) releaseStep {
+	return func(worktree *git.Worktree) (bool, error) {
+		if len(fragments) == 0 {
+			return false, nil
+		}
+
+		file := changelogFile()
+		fileName := filepath.Join(gitDir, file)
+		perm := changelogPerm
+		input, err := os.ReadFile(fileName)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return false, fmt.Errorf("Could not read file %s: %w", file, err)
+		default:
+			fi, err := os.Stat(fileName)
+			if err != nil {
+				return false, fmt.Errorf("Could not get information about file %s: %w", file, err)
+			}
+			perm = fi.Mode().Perm()
+		}
+
+		err = writeFileAtomic(fileName, []byte(insertReleaseNotes(string(input), notes)), perm)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = worktree.Add(file)
+		if err != nil {
+			return false, err
+		}
+
+		for _, fragment := range fragments {
+			_, err = worktree.Remove(fragment.file)
+			if err != nil {
+				return false, fmt.Errorf("Could not remove changelog fragment %s: %w", fragment.file, err)
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// insertReleaseNotes puts the notes after the changelog marker if there is one,
+// otherwise after the title of the changelog.
+func insertReleaseNotes(changelog, notes string) string {
+	if changelog == "" {
+		return "# Changelog\n\n" + changelogMarker + "\n\n" + notes
+	}
+
+	if before, after, ok := strings.Cut(changelog, changelogMarker+"\n"); ok {
+		return before + changelogMarker + "\n\n" + notes + after
+	}
+
+	if strings.HasPrefix(changelog, "# ") {
+		title, rest, _ := strings.Cut(changelog, "\n")
+		return title + "\n\n" + notes + "\n" + strings.TrimLeft(rest, "\n")
+	}
+
+	return notes + "\n" + changelog
+}
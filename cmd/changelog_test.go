@@ -0,0 +1,63 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectFragments(t *testing.T) {
+	previous := gitDir
+	t.Cleanup(func() { gitDir = previous })
+	gitDir = t.TempDir()
+
+	dir := filepath.Join(gitDir, changelogFragmentsDir())
+	//revive:disable-next-line:add-constant
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"123.feature.md", "1.2.bugfix.md", "README.md", ".gitkeep", "45.typo.md"} {
+		//revive:disable-next-line:add-constant
+		err := os.WriteFile(filepath.Join(dir, name), []byte("Something changed\n"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fragments, err := collectFragments()
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+
+	found := make(map[string]string, len(fragments))
+	for _, fragment := range fragments {
+		found[fragment.id] = fragment.kind
+	}
+	if len(found) != 2 || found["123"] != "feature" || found["1.2"] != "bugfix" {
+		t.Error("incorrect result: expected fragments 123 and 1.2, got", found)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the version files have the current version.",
+	Long: `Check every configured version file has the version implied by the latest tag.
+
+That is the tag itself, or the prerelease marker version after it if always_leave_version_pre is set.`,
+	Args:    cobra.NoArgs,
+	PreRunE: func(_ *cobra.Command, _ []string) error { _, err := loadConfig(); return err },
+	RunE:    check,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+// check reports every version file that does not have the expected version.
+func check(cmd *cobra.Command, _ []string) error {
+	tags, err := retrieveTags()
+	if err != nil {
+		return err
+	}
+
+	tags, _, err = releaseLineTags(tags)
+	if err != nil {
+		return err
+	}
+
+	tagVersions, _ := sortTagVersions(tags)
+	if len(tagVersions) == 0 {
+		return errors.New("There are no version tags to check against")
+	}
+
+	vExpected, err := expectedFileVersion(tagVersions[0])
+	if err != nil {
+		return err
+	}
+
+	files, err := getVersionFiles()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, file := range files {
+		// Each entry is checked on its own, so every problem is reported.
+		updates, err := planFileUpdates([]versionFile{file}, vExpected)
+		if err != nil {
+			cmd.PrintErrln(err)
+			failed++
+			continue
+		}
+
+		for _, update := range updates {
+			reportMismatches(cmd, update, file, vExpected)
+			failed++
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d version files do not have version %s", failed, len(files), vExpected)
+	}
+
+	cmd.Println("All version files have version " + vExpected)
+	return nil
+}
+
+// reportMismatches reports each line of a file that would be changed to have the expected version.
+func reportMismatches(cmd *cobra.Command, update *fileUpdate, file versionFile, vExpected string) {
+	expected, err := file.formatVersion(vExpected)
+	if err != nil {
+		expected = vExpected
+	}
+
+	inputLines := strings.Split(string(update.input), "\n")
+	outputLines := strings.Split(string(update.output), "\n")
+	for i := range inputLines {
+		if i < len(outputLines) && inputLines[i] != outputLines[i] {
+			cmd.PrintErrf("%s:%d: expected %s, found: %s\n",
+				update.path, i+1, expected, strings.TrimSpace(inputLines[i]))
+		}
+	}
+}
+
+// expectedFileVersion is the version the version files should have after the latest tag:
+// the tag itself, or the prerelease marker version after it if always_leave_version_pre is set.
+func expectedFileVersion(pvLatest *semver.ParsedVersion) (string, error) {
+	if !viper.GetBool("always_leave_version_pre") {
+		return normalizeVersion(pvLatest.String()), nil
+	}
+
+	// This is the version afterTag leaves in the files.
+	pvExpected, err := pvLatest.IncrementVersion(lowestSegment(pvLatest), true)
+	if err != nil {
+		return "", err
+	}
+	return normalizeVersion(pvExpected.String()), nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestExpectedFileVersion(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("always_leave_version_pre", nil)
+		viper.Set("initial_v", nil)
+	})
+	viper.Set("initial_v", true)
+
+	tests := []struct {
+		latest   string
+		pre      bool
+		expected string
+	}{
+		{"1.3.0", false, "v1.3.0"},
+		{"1.3.0-rc.1", false, "v1.3.0-rc.1"},
+		{"1.3.0", true, "v1.3.1-pre"},
+		{"1.3.2", true, "v1.3.3-pre"},
+		{"1.3.0-rc.1", true, "v1.3.0-rc.2-pre"},
+		{"1.3.0-alpha.2", true, "v1.3.0-alpha.3-pre"},
+	}
+
+	for _, test := range tests {
+		viper.Set("always_leave_version_pre", test.pre)
+		got, err := expectedFileVersion(semver.ParseVersion(test.latest))
+		if err != nil {
+			t.Error(ExpectNilError, err)
+		}
+		if got != test.expected {
+			t.Errorf("incorrect result for %s: expected %s, got %s", test.latest, test.expected, got)
+		}
+	}
+}
+
+// The version check expects has to be the one afterTag leaves after every kind of release.
+func TestExpectedFileVersionMatchesAfterTag(t *testing.T) {
+	t.Cleanup(func() { viper.Set("always_leave_version_pre", nil) })
+	viper.Set("always_leave_version_pre", true)
+
+	pvCurrent := semver.ParseVersion("1.2.3")
+	for _, vsIncrement := range []semver.VersionSegment{
+		semver.Major, semver.Minor, semver.Patch, semver.Alpha, semver.Beta, semver.Gamma, semver.RelCand,
+	} {
+		pvNext, err := pvCurrent.IncrementVersion(vsIncrement, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pvFinal, err := preVersionAfter(vsIncrement, pvNext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := expectedFileVersion(pvNext)
+		if err != nil {
+			t.Error(ExpectNilError, err)
+		}
+		if expected != normalizeVersion(pvFinal.String()) {
+			t.Errorf("incorrect result for a %s release of %s: check expects %s, but afterTag leaves %s",
+				vsIncrement, pvNext, expected, normalizeVersion(pvFinal.String()))
+		}
+	}
+}
+
+func TestReportMismatches(t *testing.T) {
+	update := &fileUpdate{
+		path:   "README.md",
+		input:  []byte("# Thing\n\nVersion 1.2.0\n\n  Install 1.2.0 now\n"),
+		output: []byte("# Thing\n\nVersion 1.3.0\n\n  Install 1.3.0 now\n"),
+	}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetErr(&out)
+	reportMismatches(cmd, update, versionFile{Path: "README.md"}, "1.3.0")
+
+	expected := "README.md:3: expected 1.3.0, found: Version 1.2.0\n" +
+		"README.md:5: expected 1.3.0, found: Install 1.2.0 now\n"
+	if out.String() != expected {
+		t.Errorf("incorrect result: expected %q, got %q", expected, out.String())
+	}
+}
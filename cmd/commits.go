@@ -0,0 +1,214 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/viper"
+)
+
+// defaultCommitTypes are the Conventional Commits types accepted when commit_types is not set.
+var defaultCommitTypes = []string{
+	"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test",
+}
+
+// footerRegexp matches a git trailer, like "Release-As: 2.0.0" or "Refs #123".
+var footerRegexp = regexp.MustCompile(`\A(BREAKING CHANGE|[A-Za-z][\w-]*)(?:: | #)(.*)\z`)
+
+// commitFooter is a single footer (or trailer) of a commit message.
+type commitFooter struct {
+	token string
+	value string
+}
+
+// commitFooters returns the footers in the last paragraph of a commit message.
+func commitFooters(message string) []commitFooter {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	// The first paragraph is the subject, so there are no footers without a second one.
+	if len(paragraphs) < 2 {
+		return nil
+	}
+
+	var footers []commitFooter
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		matches := footerRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			// Continuation lines belong to the footer before them.
+			if len(footers) != 0 && strings.HasPrefix(line, " ") {
+				footers[len(footers)-1].value += "\n" + strings.TrimSpace(line)
+				continue
+			}
+			return nil
+		}
+		footers = append(footers, commitFooter{token: matches[1], value: strings.TrimSpace(matches[2])})
+	}
+
+	return footers
+}
+
+// releaseAsVersion looks for a Release-As footer in the commits since the current tag.
+// When a module is released, only the commits that change it count.
+//
+// It returns nil if there is none. The newest Release-As footer wins.
+func releaseAsVersion(since plumbing.Hash, pvCurrent *semver.ParsedVersion) (*semver.ParsedVersion, error) {
+	commits, err := commitsSince(since)
+	if err != nil {
+		return nil, err
+	}
+	commits, err = moduleCommits(commits)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, commit := range commits {
+		for _, footer := range commitFooters(commit.Message) {
+			if !strings.EqualFold(footer.token, "Release-As") {
+				continue
+			}
+
+			pvForced, err := parseNewVersion(footer.value, pvCurrent)
+			if err != nil {
+				return nil, fmt.Errorf("Release-As footer in commit %s: %w", shortHash(commit.Hash), err)
+			}
+
+			slog.Info(fmt.Sprintf("Release-As footer in commit %s requests version %s",
+				shortHash(commit.Hash), normalizeVersion(pvForced.String())))
+			return pvForced, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // Having no Release-As footer is not an error.
+}
+
+func commitTypes() []string {
+	types := viper.GetStringSlice("commit_types")
+	if len(types) == 0 {
+		return defaultCommitTypes
+	}
+	return types
+}
+
+// conventionalCommit is a commit message split up according to Conventional Commits.
+type conventionalCommit struct {
+	kind        string
+	scope       string
+	breaking    bool
+	description string
+	footers     []commitFooter
+}
+
+// lintError is a problem with a commit message, at a line and column counted from 1.
+type lintError struct {
+	line    int
+	column  int
+	message string
+}
+
+func (e lintError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.line, e.column, e.message)
+}
+
+// parseConventionalCommit parses a commit message according to Conventional Commits,
+// only accepting the given types.
+//
+// The commit is returned even if there are problems with the message, as far as it could be parsed.
+func parseConventionalCommit(message string, types []string) (*conventionalCommit, []lintError) {
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	header := lines[0]
+
+	var cc conventionalCommit
+	lintErr := func(column int, format string, args ...any) (*conventionalCommit, []lintError) {
+		return &cc, []lintError{{line: 1, column: column, message: fmt.Sprintf(format, args...)}}
+	}
+
+	if strings.TrimSpace(header) == "" {
+		return lintErr(1, "the commit message is empty")
+	}
+
+	pos := strings.IndexFunc(header, func(r rune) bool { return !unicode.IsLetter(r) })
+	if pos == -1 {
+		pos = len(header)
+	}
+	if pos == 0 {
+		return lintErr(1, "expected a type, like %s", strings.Join(types, ", "))
+	}
+	cc.kind = header[:pos]
+	if !slices.Contains(types, cc.kind) {
+		return lintErr(1, "unknown type %q, expected one of %s", cc.kind, strings.Join(types, ", "))
+	}
+
+	if strings.HasPrefix(header[pos:], "(") {
+		end := strings.IndexByte(header[pos:], ')')
+		if end == -1 {
+			return lintErr(pos+1, "the scope is not closed with ')'")
+		}
+		cc.scope = header[pos+1 : pos+end]
+		if strings.TrimSpace(cc.scope) == "" {
+			return lintErr(pos+2, "the scope is empty")
+		}
+		pos += end + 1
+	}
+
+	if strings.HasPrefix(header[pos:], "!") {
+		cc.breaking = true
+		pos++
+	}
+
+	if !strings.HasPrefix(header[pos:], ":") {
+		return lintErr(pos+1, "expected ':' after the type")
+	}
+	pos++
+	if !strings.HasPrefix(header[pos:], " ") {
+		return lintErr(pos+1, "expected a space after ':'")
+	}
+	pos++
+
+	cc.description = strings.TrimSpace(header[pos:])
+	if cc.description == "" {
+		return lintErr(pos+1, "the description is empty")
+	}
+
+	var errs []lintError
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		errs = append(errs, lintError{line: 2, column: 1, message: "expected a blank line after the header"})
+	}
+
+	cc.footers = commitFooters(message)
+	for _, footer := range cc.footers {
+		if footer.token == "BREAKING CHANGE" || footer.token == "BREAKING-CHANGE" {
+			cc.breaking = true
+		}
+	}
+
+	return &cc, errs
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/viper"
+)
+
+func TestCommitFooters(t *testing.T) {
+	footers := commitFooters("feat: thing\n\nSome body.\n\nRelease-As: 2.0.0\nRefs #123\nBREAKING CHANGE: the API\n  is different")
+	expected := []commitFooter{
+		{token: "Release-As", value: "2.0.0"},
+		{token: "Refs", value: "123"},
+		{token: "BREAKING CHANGE", value: "the API\nis different"},
+	}
+	if diff := cmp.Diff(expected, footers, cmp.AllowUnexported(commitFooter{})); diff != "" {
+		t.Error(diff)
+	}
+
+	footers = commitFooters("feat: thing\n\nRelease-As: 2.0.0")
+	if len(footers) != 1 {
+		t.Error("incorrect result: expected 1 footer, got", footers)
+	}
+
+	footers = commitFooters("Release-As: 2.0.0")
+	if footers != nil {
+		t.Error("incorrect result: a subject is not a footer, got", footers)
+	}
+
+	footers = commitFooters("feat: thing\n\nThis is a body: not footers.\nReally.")
+	if footers != nil {
+		t.Error("incorrect result: a body is not footers, got", footers)
+	}
+}
+
+func TestParseConventionalCommit(t *testing.T) {
+	types := []string{"feat", "fix"}
+
+	cc, errs := parseConventionalCommit("feat(parser)!: add things\n\nBody.\n", types)
+	if len(errs) != 0 {
+		t.Error(ExpectNilError, errs)
+	}
+	if cc.kind != "feat" || cc.scope != "parser" || !cc.breaking || cc.description != "add things" {
+		t.Error("incorrect result: got", cc)
+	}
+
+	cc, errs = parseConventionalCommit("fix: a bug\n\nBREAKING CHANGE: it changed", types)
+	if len(errs) != 0 {
+		t.Error(ExpectNilError, errs)
+	}
+	if !cc.breaking {
+		t.Error("incorrect result: expected a breaking change from the footer")
+	}
+
+	failures := map[string]string{
+		"":                       "1:1: the commit message is empty",
+		"updated things":         `1:1: unknown type "updated", expected one of feat, fix`,
+		": things":               "1:1: expected a type, like feat, fix",
+		"feat(parser: things":    "1:5: the scope is not closed with ')'",
+		"feat(): things":         "1:6: the scope is empty",
+		"feat things":            "1:5: expected ':' after the type",
+		"feat:things":            "1:6: expected a space after ':'",
+		"feat: ":                 "1:7: the description is empty",
+		"feat: things\nmore":     "2:1: expected a blank line after the header",
+		"fix(a)!:  ":             "1:10: the description is empty",
+		"feat!things":            "1:6: expected ':' after the type",
+		"feat(parser)! things":   "1:14: expected ':' after the type",
+		"feat(parser) : things":  "1:13: expected ':' after the type",
+		"feat: things\n\n\nmore": "",
+	}
+	for message, expected := range failures {
+		_, errs := parseConventionalCommit(message, types)
+		got := ""
+		if len(errs) != 0 {
+			got = errs[0].Error()
+		}
+		if got != expected {
+			t.Errorf("incorrect result for %q: expected %q, got %q", message, expected, got)
+		}
+	}
+}
+
+func TestReleaseAsVersion(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod":     "module example.com/m\n",
+		"lib/go.mod": "module example.com/m/lib\n",
+	})
+	first := testCommit(t, worktree, "chore: init", nil)
+	testCommit(t, worktree, "feat: lib\n\nRelease-As: 2.0.0\n", map[string]string{"lib/lib.go": "package lib\n"})
+	testCommit(t, worktree, "fix: root", map[string]string{"root.go": "package m\n"})
+	t.Cleanup(func() { viper.Set("module", nil) })
+
+	tests := map[string]string{
+		"":    "2.0.0",
+		".":   "",
+		"lib": "2.0.0",
+	}
+	for module, expected := range tests {
+		viper.Set("module", module)
+		pvForced, err := releaseAsVersion(first, semver.ParseVersion("1.0.0"))
+		if err != nil {
+			t.Error(module, ExpectNilError, err)
+		}
+
+		got := ""
+		if pvForced != nil {
+			got = pvForced.String()
+		}
+		if got != expected {
+			t.Errorf("incorrect result for module %q: expected %q, got %q", module, expected, got)
+		}
+	}
+}
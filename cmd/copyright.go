@@ -0,0 +1,171 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/spf13/viper"
+)
+
+// defaultCopyrightPattern finds lines like "Copyright © 2023, 2024" or "Copyright (c) 2020-2023".
+const defaultCopyrightPattern = `(?i)copyright\s+(?:©\s*|\(c\)\s*)?(\d{4}(?:\s*[-,]\s*\d{4})*)`
+
+// copyrightSettings is the copyright setting.
+type copyrightSettings struct {
+	// Files are the globs of the files to update, like "**/*.go".
+	Files []string `mapstructure:"files"`
+	// Exclude are the globs of the matching files to leave alone.
+	Exclude []string `mapstructure:"exclude"`
+	// Pattern is a regular expression whose first group is the years, like "2023, 2024".
+	Pattern string `mapstructure:"pattern"`
+}
+
+// getCopyrightSettings reads the copyright setting.
+//
+// It returns nil if no files are configured.
+func getCopyrightSettings() (*copyrightSettings, *regexp.Regexp, error) {
+	var settings copyrightSettings
+	err := viper.UnmarshalKey("copyright", &settings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not read copyright: %w", err)
+	}
+	if len(settings.Files) == 0 {
+		return nil, nil, nil
+	}
+
+	if settings.Pattern == "" {
+		settings.Pattern = defaultCopyrightPattern
+	}
+	re, err := regexp.Compile(settings.Pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not compile copyright pattern %s: %w", settings.Pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, nil, errors.New("The copyright pattern needs a group for the years")
+	}
+
+	return &settings, re, nil
+}
+
+// extendYears adds year to a list or range of years, like "2023, 2024" or "2020-2023".
+//
+// A range is extended to end at year, otherwise year is added to the list.
+// The years are returned unchanged if they already reach year.
+func extendYears(years string, year int) string {
+	if len(years) < 4 {
+		return years
+	}
+
+	last, err := strconv.Atoi(years[len(years)-4:])
+	if err != nil || last >= year {
+		return years
+	}
+
+	rest := strings.TrimRight(years[:len(years)-4], " \t")
+	if strings.HasSuffix(rest, "-") {
+		return years[:len(years)-4] + strconv.Itoa(year)
+	}
+
+	return fmt.Sprintf("%s, %d", years, year)
+}
+
+// updateCopyright extends the years in the first copyright notice of input.
+//
+// It returns nil if nothing changed.
+func updateCopyright(input []byte, re *regexp.Regexp, year int) []byte {
+	loc := re.FindSubmatchIndex(input)
+	if loc == nil || loc[2] < 0 {
+		return nil
+	}
+
+	years := string(input[loc[2]:loc[3]])
+	extended := extendYears(years, year)
+	if extended == years {
+		return nil
+	}
+
+	output := make([]byte, 0, len(input)+len(extended)-len(years))
+	output = append(output, input[:loc[2]]...)
+	output = append(output, extended...)
+	output = append(output, input[loc[3]:]...)
+
+	return output
+}
+
+// copyrightStep extends the copyright years of the files in the copyright setting to the current year.
+func copyrightStep() releaseStep {
+	return func(worktree *git.Worktree) (bool, error) {
+		settings, re, err := getCopyrightSettings()
+		if err != nil || settings == nil {
+			return false, err
+		}
+
+		files, err := matchTrackedFiles(settings.Files, settings.Exclude)
+		if err != nil {
+			return false, fmt.Errorf("%w in copyright", err)
+		}
+		inModule, err := moduleFileFilter()
+		if err != nil {
+			return false, err
+		}
+		files = slices.DeleteFunc(files, func(file string) bool { return !inModule(file) })
+
+		year := time.Now().Year()
+		staged := false
+		for _, file := range files {
+			input, err := os.ReadFile(filepath.Join(gitDir, file))
+			if err != nil {
+				return false, fmt.Errorf("Could not read file %s: %w", file, err)
+			}
+			if isBinary(input) {
+				slog.Warn(fmt.Sprintf("Skipping binary file %s", file))
+				continue
+			}
+
+			output := updateCopyright(input, re, year)
+			if output == nil {
+				continue
+			}
+
+			err = writeStaged(worktree, file, output)
+			if err != nil {
+				return false, err
+			}
+			staged = true
+		}
+
+		return staged, nil
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetCopyrightSettings(t *testing.T) {
+	t.Cleanup(func() { viper.Set("copyright", nil) })
+
+	viper.Set("copyright", map[string]any{"files": []string{"**/*.go"}, "pattern": `copyright \d{4}`})
+	_, _, err := getCopyrightSettings()
+	if err == nil {
+		t.Error(ExpectError, "for a pattern without a group")
+	}
+
+	viper.Set("copyright", map[string]any{"files": []string{"**/*.go"}})
+	settings, re, err := getCopyrightSettings()
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if settings == nil || re.String() != defaultCopyrightPattern {
+		t.Error("incorrect result: expected the default pattern, got", re)
+	}
+}
+
+func TestExtendYears(t *testing.T) {
+	tests := map[string]string{
+		"2024":            "2024, 2025",
+		"2023, 2024":      "2023, 2024, 2025",
+		"2020-2024":       "2020-2025",
+		"2020 - 2024":     "2020 - 2025",
+		"2019, 2021-2023": "2019, 2021-2025",
+		"2023, 2025":      "2023, 2025",
+		"2020-2026":       "2020-2026",
+	}
+
+	for years, expected := range tests {
+		if got := extendYears(years, 2025); got != expected {
+			t.Errorf("incorrect result for %q: expected %q, got %q", years, expected, got)
+		}
+	}
+}
+
+func TestUpdateCopyright(t *testing.T) {
+	re := regexp.MustCompile(defaultCopyrightPattern)
+
+	input := "/*\nCopyright © 2023, 2024 Someone\n*/\n// Copyright (c) 2024 Someone Else\n"
+	expected := "/*\nCopyright © 2023, 2024, 2025 Someone\n*/\n// Copyright (c) 2024 Someone Else\n"
+	if got := string(updateCopyright([]byte(input), re, 2025)); got != expected {
+		t.Errorf("incorrect result: expected %q, got %q", expected, got)
+	}
+
+	if got := updateCopyright([]byte("Copyright (C) 2010-2025 Someone\n"), re, 2025); got != nil {
+		t.Error("incorrect result: expected no change for a current notice, got", string(got))
+	}
+
+	if got := updateCopyright([]byte("No notice here\n"), re, 2025); got != nil {
+		t.Error("incorrect result: expected no change without a notice, got", string(got))
+	}
+}
@@ -24,44 +24,288 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"slices"
 	"strings"
+	"text/template"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/csjewell/git-next-tag/semver"
+	"github.com/spf13/viper"
 )
 
-func replaceInFile(fileName, newVersion string) error {
-	input, err := os.ReadFile(fileName)
+// versionFile is an entry of the version_files setting.
+//
+// It can also be given as just the path, which updates every version in the file.
+type versionFile struct {
+	// Path can also be a glob, like charts/**/Chart.yaml, matching files tracked by git.
+	Path string `mapstructure:"path"`
+	// Exclude are globs of files that Path should not match.
+	Exclude []string `mapstructure:"exclude"`
+	// Line is a regular expression that a line has to match for its versions to be updated.
+	Line string `mapstructure:"line"`
+	// Regex is a regular expression whose first group is the version to update.
+	Regex string `mapstructure:"regex"`
+	// Marker is text, like a // git-next-tag:version comment, that a line has to contain
+	// for its versions to be updated.
+	Marker string `mapstructure:"marker"`
+	// Count is how many versions are expected to be found. If Line, Regex or Marker is set, it defaults to 1.
+	Count int `mapstructure:"count"`
+
+	// JSON, YAML and TOML are key paths, like .version or package.version,
+	// to the one version in a file of that format.
+	JSON string `mapstructure:"json"`
+	YAML string `mapstructure:"yaml"`
+	TOML string `mapstructure:"toml"`
+
+	// Format is a template for how the version is written in this file,
+	// like {{.Major}}.{{.Minor}}.{{.Patch}}{{.Prerelease}}.
+	Format string `mapstructure:"format"`
+}
+
+// getVersionFiles reads the version_files setting.
+func getVersionFiles() ([]versionFile, error) {
+	var files []versionFile
+	err := viper.UnmarshalKey("version_files", &files, viper.DecodeHook(
+		func(from, to reflect.Type, data any) (any, error) {
+			if from.Kind() == reflect.String && to == reflect.TypeOf(versionFile{}) {
+				return versionFile{Path: data.(string)}, nil //nolint:forcetypeassert // Checked by Kind.
+			}
+			return data, nil
+		},
+	))
 	if err != nil {
-		return fmt.Errorf("Could not read file %s: %w", fileName, err)
+		return nil, fmt.Errorf("Could not read version_files: %w", err)
+	}
+
+	// Releasing a single module only updates the files in it.
+	dir := moduleDir()
+
+	expanded := make([]versionFile, 0, len(files))
+	for _, file := range files {
+		if file.Path == "" {
+			return nil, errors.New("Every entry in version_files needs a path")
+		}
+
+		structured := 0
+		for _, keyPath := range []string{file.JSON, file.YAML, file.TOML} {
+			if keyPath != "" {
+				structured++
+			}
+		}
+		if structured > 1 || (structured == 1 && (file.isScoped() || file.Count != 0)) {
+			return nil, fmt.Errorf("The version_files entry for %s can only use one of json, yaml, toml, "+
+				"or line, regex and marker", file.Path)
+		}
+
+		matches, err := file.expand()
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if inDir(match.Path, dir) {
+				expanded = append(expanded, match)
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+// expand turns an entry with a glob into an entry for each tracked file it matches.
+func (vf versionFile) expand() ([]versionFile, error) {
+	if !strings.ContainsAny(vf.Path, "*?[{\\") {
+		if !doublestar.ValidatePattern(vf.Path) {
+			return nil, fmt.Errorf("%s in version_files is not a valid glob", vf.Path)
+		}
+		return []versionFile{vf}, nil
 	}
 
-	fi, err := os.Stat(fileName)
+	names, err := matchTrackedFiles([]string{vf.Path}, vf.Exclude)
 	if err != nil {
-		return fmt.Errorf("Could not get information about file %s: %w", fileName, err)
+		return nil, fmt.Errorf("%w in version_files", err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s in version_files does not match any tracked files", vf.Path)
 	}
-	perm := fi.Mode().Perm()
 
-	lines := strings.Split(string(input), "\n")
+	matches := make([]versionFile, 0, len(names))
+	for _, name := range names {
+		file := vf
+		file.Path = name
+		file.Exclude = nil
+		matches = append(matches, file)
+	}
 
-	for iLine, line := range lines {
-		finds := semver.Regexp.FindAllString(line, -1)
-		finds = slices.Compact(finds)
-		if len(finds) != 0 {
-			for _, find := range finds {
-				if find != newVersion {
-					line = strings.ReplaceAll(line, find, newVersion)
-				}
+	return matches, nil
+}
+
+// matchTrackedFiles finds the tracked files matching any of the globs, and none of the excluded globs.
+//
+// Only considering tracked files also leaves out everything .gitignore does.
+func matchTrackedFiles(patterns, excludes []string) ([]string, error) {
+	for _, pattern := range append(slices.Clone(patterns), excludes...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("%s is not a valid glob", pattern)
+		}
+	}
+
+	tracked, err := trackedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	// The patterns were validated above, so matching cannot fail.
+	matchAny := func(patterns []string, name string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := doublestar.Match(pattern, name)
+			return matched
+		})
+	}
+
+	var matches []string
+	for _, name := range tracked {
+		if matchAny(patterns, name) && !matchAny(excludes, name) {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches, nil
+}
+
+// isScoped checks whether only some of the versions in the file are to be updated.
+func (vf versionFile) isScoped() bool {
+	return vf.Line != "" || vf.Regex != "" || vf.Marker != ""
+}
+
+// expectedCount is how many versions are expected to be found, or 0 if any number is fine.
+func (vf versionFile) expectedCount() int {
+	if vf.Count == 0 && vf.isScoped() {
+		return 1
+	}
+	return vf.Count
+}
+
+// fileUpdate is the new contents of a file, waiting to be written.
+type fileUpdate struct {
+	// path is relative to the top of the repository.
+	path   string
+	input  []byte
+	output []byte
+	perm   fs.FileMode
+}
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files.
+const utf8BOM = "\xEF\xBB\xBF"
+
+// planFileUpdates works out the new contents of every version file, without writing anything.
+//
+// Only files whose contents change are returned. Binary files are skipped.
+func planFileUpdates(files []versionFile, newVersion string) ([]*fileUpdate, error) {
+	var updates []*fileUpdate
+	planned := make(map[string]*fileUpdate)
+
+	for _, file := range files {
+		// A file can be in more than one entry, each updating a different part of it.
+		update, ok := planned[file.Path]
+		if !ok {
+			fileName := filepath.Join(gitDir, file.Path)
+			input, err := os.ReadFile(fileName)
+			if err != nil {
+				return nil, fmt.Errorf("Could not read file %s: %w", file.Path, err)
+			}
+
+			fi, err := os.Stat(fileName)
+			if err != nil {
+				return nil, fmt.Errorf("Could not get information about file %s: %w", file.Path, err)
 			}
 
-			lines[iLine] = line
+			update = &fileUpdate{path: file.Path, input: input, output: input, perm: fi.Mode().Perm()}
+			planned[file.Path] = update
+			updates = append(updates, update)
+		}
+
+		if isBinary(update.input) {
+			slog.Warn("Skipping binary file " + file.Path)
+			continue
+		}
+
+		fileVersion, err := file.formatVersion(newVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		// Versions are replaced in text without a byte order mark or carriage returns,
+		// and both are put back afterwards.
+		text := string(update.output)
+		bom := strings.HasPrefix(text, utf8BOM)
+		text = strings.TrimPrefix(text, utf8BOM)
+		crlf := strings.Contains(text, "\r\n") && strings.Count(text, "\r\n") == strings.Count(text, "\n")
+		if crlf {
+			text = strings.ReplaceAll(text, "\r\n", "\n")
+		}
+
+		text, err = file.updateVersions(text, fileVersion)
+		if err != nil {
+			return nil, err
 		}
+
+		if crlf {
+			text = strings.ReplaceAll(text, "\n", "\r\n")
+		}
+		if bom {
+			text = utf8BOM + text
+		}
+		update.output = []byte(text)
 	}
 
-	output := strings.Join(lines, "\n")
-	err = os.WriteFile(fileName, []byte(output), perm)
+	return slices.DeleteFunc(updates, func(update *fileUpdate) bool {
+		return bytes.Equal(update.input, update.output)
+	}), nil
+}
+
+// isBinary guesses whether a file is binary the way git does, by looking for a NUL byte near the start.
+func isBinary(input []byte) bool {
+	const sniffLength = 8000
+	return bytes.IndexByte(input[:min(len(input), sniffLength)], 0) != -1
+}
+
+// write writes the new contents of the file.
+func (fu *fileUpdate) write() error {
+	return writeFileAtomic(filepath.Join(gitDir, fu.path), fu.output, fu.perm)
+}
+
+// writeFileAtomic writes to a temporary file next to the file, then renames it over the file,
+// so the file is never left half written.
+func writeFileAtomic(fileName string, data []byte, perm fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary file for %s: %w", fileName, err)
+	}
+	// Once renamed, removing the temporary file fails harmlessly.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fileName)
+	}
 	if err != nil {
 		return fmt.Errorf("Could not write to file %s: %w", fileName, err)
 	}
@@ -69,16 +313,143 @@ func replaceInFile(fileName, newVersion string) error {
 	return nil
 }
 
-func createVersionDotGoFile(pkg, fileName string) error {
-	versionFile := `
-package ` + pkg + `
+// formatVersion writes the version the way the file's format asks for.
+//
+// Without a format, the version is written the same way it is tagged.
+func (vf versionFile) formatVersion(version string) (string, error) {
+	if vf.Format == "" {
+		return version, nil
+	}
+
+	tmpl, err := template.New(vf.Path).Option("missingkey=error").Parse(vf.Format)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the format for %s: %w", vf.Path, err)
+	}
+
+	pv := semver.ParseVersion(version)
+	if pv == nil {
+		return "", fmt.Errorf("%s is not a valid version", version)
+	}
+
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, newVersionData(pv))
+	if err != nil {
+		return "", fmt.Errorf("Could not format the version for %s: %w", vf.Path, err)
+	}
+
+	return sb.String(), nil
+}
+
+// updateVersions updates the version(s) in the file's contents, in the way the entry asks for.
+func (vf versionFile) updateVersions(input, newVersion string) (string, error) {
+	var (
+		output string
+		err    error
+	)
+	switch {
+	case vf.JSON != "":
+		output, err = replaceJSON(input, vf.JSON, newVersion)
+	case vf.YAML != "":
+		output, err = replaceYAML(input, vf.YAML, newVersion)
+	case vf.TOML != "":
+		output, err = replaceTOML(input, vf.TOML, newVersion)
+	default:
+		return vf.replaceVersions(input, newVersion)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Could not update the version in %s: %w", vf.Path, err)
+	}
+
+	return output, nil
+}
+
+// replaceVersions replaces the versions in the file's contents that the entry selects.
+func (vf versionFile) replaceVersions(input, newVersion string) (string, error) {
+	var (
+		rxLine, rxVersion *regexp.Regexp
+		err               error
+	)
+	if vf.Line != "" {
+		rxLine, err = regexp.Compile(vf.Line)
+		if err != nil {
+			return "", fmt.Errorf("Could not compile line %s for %s: %w", vf.Line, vf.Path, err)
+		}
+	}
+	if vf.Regex != "" {
+		rxVersion, err = regexp.Compile(vf.Regex)
+		if err != nil {
+			return "", fmt.Errorf("Could not compile regex %s for %s: %w", vf.Regex, vf.Path, err)
+		}
+		if rxVersion.NumSubexp() == 0 {
+			return "", fmt.Errorf("Regex %s for %s needs a group around the version", vf.Regex, vf.Path)
+		}
+	}
+
+	found := 0
+	lines := strings.Split(input, "\n")
+
+	for iLine, line := range lines {
+		if rxLine != nil && !rxLine.MatchString(line) {
+			continue
+		}
+		if vf.Marker != "" && !strings.Contains(line, vf.Marker) {
+			continue
+		}
+
+		// Each span is the start and end of a version within the line.
+		var spans [][]int
+		if rxVersion != nil {
+			for _, match := range rxVersion.FindAllStringSubmatchIndex(line, -1) {
+				if match[2] != -1 {
+					spans = append(spans, match[2:4])
+				}
+			}
+		} else {
+			spans = semver.Regexp.FindAllStringIndex(line, -1)
+		}
+		found += len(spans)
+
+		// Replace from the end, so the earlier spans stay where they are.
+		for i := len(spans) - 1; i >= 0; i-- {
+			line = line[:spans[i][0]] + newVersion + line[spans[i][1]:]
+		}
+		lines[iLine] = line
+	}
+
+	if expected := vf.expectedCount(); expected != 0 && found != expected {
+		return "", fmt.Errorf("Expected to find %d version(s) to update in %s, but found %d", expected, vf.Path, found)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// createVersionDotGoFile writes a version.go file for a package.
+//
+// With ldflagsVars, Version is a plain string, and Commit and Date variables are added,
+// so all three can be set with -ldflags -X when building.
+func createVersionDotGoFile(pkg, fileName, version string, ldflagsVars bool) error {
+	contents := `package ` + pkg + `
 
 // Version is the current version of the library or command.
-var Version = func() string { return "v0.1.0-pre" }()
+var Version = func() string { return "` + version + `" }()
 `
+	if ldflagsVars {
+		contents = `package ` + pkg + `
+
+// These are set with -ldflags -X when building. See git next-tag ldflags.
+var (
+	// Version is the current version of the library or command.
+	Version = "` + version + `"
+	// Commit is the commit the library or command was built from.
+	Commit = "unknown"
+	// Date is when that commit was made.
+	Date = "unknown"
+)
+`
+	}
 
 	//revive:disable:add-constant
-	err := os.WriteFile(fileName, []byte(versionFile), 0o600)
+	err := os.WriteFile(fileName, []byte(contents), 0o600)
 	//revive:enable:add-constant
 	if err != nil {
 		return fmt.Errorf("Could not write to file %s: %w", fileName, err)
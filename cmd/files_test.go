@@ -0,0 +1,249 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const goMod = `module example.com/thing
+
+// git-next-tag:version v1.2.3
+require (
+	example.com/other v0.4.1
+	example.com/more v2.0.0-rc.1
+)
+`
+
+func TestReplaceVersions(t *testing.T) {
+	vf := versionFile{Path: "go.mod"}
+	output, err := vf.replaceVersions(goMod, "v1.3.0")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if output != `module example.com/thing
+
+// git-next-tag:version v1.3.0
+require (
+	example.com/other v1.3.0
+	example.com/more v1.3.0
+)
+` {
+		t.Error("incorrect result: expected every version replaced, got", output)
+	}
+
+	scoped := map[string]versionFile{
+		"marker": {Path: "go.mod", Marker: "// git-next-tag:version"},
+		"line":   {Path: "go.mod", Line: `^//`},
+		"regex":  {Path: "go.mod", Regex: `:version (\S+)`},
+	}
+	for name, vf := range scoped {
+		output, err := vf.replaceVersions(goMod, "v1.3.0")
+		if err != nil {
+			t.Error(name, ExpectNilError, err)
+		}
+		if output != `module example.com/thing
+
+// git-next-tag:version v1.3.0
+require (
+	example.com/other v0.4.1
+	example.com/more v2.0.0-rc.1
+)
+` {
+			t.Error(name, "incorrect result: expected one version replaced, got", output)
+		}
+	}
+
+	vf = versionFile{Path: "go.mod", Line: `^\texample`}
+	_, err = vf.replaceVersions(goMod, "v1.3.0")
+	if err == nil {
+		t.Error(ExpectError, "for two versions when one was expected")
+	}
+
+	vf = versionFile{Path: "go.mod", Line: `^\texample`, Count: 2}
+	_, err = vf.replaceVersions(goMod, "v1.3.0")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+
+	vf = versionFile{Path: "go.mod", Regex: `version \S+`}
+	_, err = vf.replaceVersions(goMod, "v1.3.0")
+	if err == nil {
+		t.Error(ExpectError, "for a regex without a group")
+	}
+}
+
+func TestStructuredVersions(t *testing.T) {
+	tests := []struct {
+		file     versionFile
+		input    string
+		expected string
+	}{
+		{
+			versionFile{Path: "package.json", JSON: ".version"},
+			"{\n  \"name\": \"thing\",\n  \"dependencies\": {\"version\": \"1.0.0\"},\n  \"version\" :  \"1.2.3\"\n}\n",
+			"{\n  \"name\": \"thing\",\n  \"dependencies\": {\"version\": \"1.0.0\"},\n  \"version\" :  \"1.3.0\"\n}\n",
+		},
+		{
+			versionFile{Path: "package.json", JSON: "packages.1.version"},
+			`{"packages": [{"version": "1.0.0"}, {"version": "1.2.3"}]}`,
+			`{"packages": [{"version": "1.0.0"}, {"version": "1.3.0"}]}`,
+		},
+		{
+			versionFile{Path: "Chart.yaml", YAML: "appVersion"},
+			"# The chart.\nversion: 0.4.0\nappVersion: \"1.2.3\" # Keep quoted.\n",
+			"# The chart.\nversion: 0.4.0\nappVersion: \"1.3.0\" # Keep quoted.\n",
+		},
+		{
+			versionFile{Path: "values.yaml", YAML: "image.tag"},
+			"image:\n  name: 'thing' # é\n  tag: 1.2.3\n",
+			"image:\n  name: 'thing' # é\n  tag: 1.3.0\n",
+		},
+		{
+			versionFile{Path: "Cargo.toml", TOML: "package.version"},
+			"[package]\nname = \"thing\"\nversion = \"1.2.3\" # Keep this.\n\n[dependencies]\nserde = { version = \"1.0\" }\n",
+			"[package]\nname = \"thing\"\nversion = \"1.3.0\" # Keep this.\n\n[dependencies]\nserde = { version = \"1.0\" }\n",
+		},
+		{
+			versionFile{Path: "pyproject.toml", TOML: "tool.thing.version"},
+			"[tool]\nthing = { name = 'thing', version = '1.2.3' }\n",
+			"[tool]\nthing = { name = 'thing', version = '1.3.0' }\n",
+		},
+	}
+
+	for _, test := range tests {
+		output, err := test.file.updateVersions(test.input, "1.3.0")
+		if err != nil {
+			t.Error(test.file.Path, ExpectNilError, err)
+		}
+		if output != test.expected {
+			t.Errorf("incorrect result for %s: expected %q, got %q", test.file.Path, test.expected, output)
+		}
+	}
+
+	failures := []struct {
+		file  versionFile
+		input string
+	}{
+		{versionFile{Path: "package.json", JSON: ".version"}, `{"name": "thing"}`},
+		{versionFile{Path: "package.json", JSON: ".private"}, `{"private": true}`},
+		{versionFile{Path: "Chart.yaml", YAML: "appVersion"}, "version: 1.2.3\n"},
+		{versionFile{Path: "Cargo.toml", TOML: "package.version"}, "[workspace.package]\nversion = \"1.2.3\"\n"},
+	}
+	for _, failure := range failures {
+		_, err := failure.file.updateVersions(failure.input, "1.3.0")
+		if err == nil {
+			t.Error(failure.file.Path, ExpectError)
+		}
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	vf := versionFile{Path: "package.json"}
+	version, err := vf.formatVersion("v1.2.3-rc.1-pre")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if version != "v1.2.3-rc.1-pre" {
+		t.Error("incorrect result: expected v1.2.3-rc.1-pre, got", version)
+	}
+
+	vf.Format = "{{.Major}}.{{.Minor}}.{{.Patch}}{{.Prerelease}}"
+	version, err = vf.formatVersion("v1.2.3-rc.1-pre")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if version != "1.2.3-rc.1-pre" {
+		t.Error("incorrect result: expected 1.2.3-rc.1-pre, got", version)
+	}
+
+	vf.Format = "{{.Major}}.{{.Minor}}"
+	version, err = vf.formatVersion("v1.2.3")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if version != "1.2" {
+		t.Error("incorrect result: expected 1.2, got", version)
+	}
+
+	vf.Format = "{{.Build}}"
+	_, err = vf.formatVersion("v1.2.3")
+	if err == nil {
+		t.Error(ExpectError)
+	}
+}
+
+func TestPlanFileUpdates(t *testing.T) {
+	previous := gitDir
+	t.Cleanup(func() { gitDir = previous })
+	gitDir = t.TempDir()
+	files := map[string]string{
+		"crlf.go":     "// Version 1.2.3\r\nvar Version = \"1.2.3\"\r\n",
+		"bom.json":    "\xEF\xBB\xBF{\"version\": \"1.2.3\"}\n",
+		"binary.dat":  "1.2.3\x00",
+		"current.txt": "1.3.0\n",
+	}
+	for name, contents := range files {
+		//revive:disable-next-line:add-constant
+		err := os.WriteFile(filepath.Join(gitDir, name), []byte(contents), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updates, err := planFileUpdates([]versionFile{
+		{Path: "crlf.go", Line: "^var"},
+		{Path: "crlf.go", Line: "^//"},
+		{Path: "bom.json", JSON: ".version"},
+		{Path: "binary.dat"},
+		{Path: "current.txt"},
+	}, "1.3.0")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+
+	expected := map[string]string{
+		"crlf.go":  "// Version 1.3.0\r\nvar Version = \"1.3.0\"\r\n",
+		"bom.json": "\xEF\xBB\xBF{\"version\": \"1.3.0\"}\n",
+	}
+	if len(updates) != len(expected) {
+		t.Error("incorrect result: expected only the changed text files, got", len(updates))
+	}
+	for _, update := range updates {
+		if string(update.output) != expected[update.path] {
+			t.Errorf("incorrect result for %s: expected %q, got %q", update.path, expected[update.path], update.output)
+		}
+
+		err = update.write()
+		if err != nil {
+			t.Error(ExpectNilError, err)
+		}
+		written, _ := os.ReadFile(filepath.Join(gitDir, update.path))
+		if string(written) != expected[update.path] {
+			t.Errorf("incorrect result: wrote %q to %s", written, update.path)
+		}
+	}
+}
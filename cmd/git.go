@@ -27,11 +27,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/manifoldco/promptui"
+	"github.com/spf13/viper"
 )
 
 // isTreeClean checks if the tree is clean and cancels if requested.
@@ -61,9 +64,17 @@ func isTreeClean() error {
 	return nil
 }
 
-// retrieveTags retrieves all tags in the current repository.
-func retrieveTags() (map[string]*object.Tag, error) {
-	tags := make(map[string]*object.Tag)
+// retrieveTags retrieves the tags in the current repository that start with the tag prefix,
+// along with the commit each one is on.
+//
+// The tags are named without the prefix.
+func retrieveTags() (map[string]plumbing.Hash, error) {
+	return retrievePrefixedTags(tagPrefix())
+}
+
+// retrievePrefixedTags retrieves the tags that start with a prefix, named without it.
+func retrievePrefixedTags(prefix string) (map[string]plumbing.Hash, error) {
+	tags := make(map[string]plumbing.Hash)
 
 	// Start by checking if there are any tags
 	iter, err := repo.Tags()
@@ -72,11 +83,28 @@ func retrieveTags() (map[string]*object.Tag, error) {
 	}
 
 	if err := iter.ForEach(func(ref *plumbing.Reference) error {
-		obj, err := repo.TagObject(ref.Hash())
-		if err != nil {
+		name, ok := strings.CutPrefix(ref.Name().Short(), prefix)
+		if !ok {
+			return nil
+		}
+		hash := ref.Hash()
+
+		// Annotated tags point at a tag object, lightweight tags point at the commit itself.
+		obj, err := repo.TagObject(hash)
+		switch {
+		case err == nil:
+			commit, err := obj.Commit()
+			if err != nil {
+				slog.Debug(fmt.Sprintf("Skipping tag %s: %s", ref.Name().Short(), err))
+				return nil
+			}
+			hash = commit.Hash
+		case errors.Is(err, plumbing.ErrObjectNotFound):
+		default:
 			return err
 		}
-		tags[obj.Name] = obj
+
+		tags[name] = hash
 		return nil
 	}); err != nil {
 		return nil, err
@@ -85,10 +113,155 @@ func retrieveTags() (map[string]*object.Tag, error) {
 	return tags, nil
 }
 
-// doTagging applies a new tag to the repository and pushes to all remotes.
-func doTagging(tag string, head plumbing.Hash, dryrun bool) error {
+// remoteTagExists checks every remote for the tags.
+//
+// It returns the first tag found and the name of the remote that has it, or "" if none do.
+func remoteTagExists(tags ...string) (string, string, error) {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, remote := range remotes {
+		refs, err := remote.List(&git.ListOptions{})
+		if err != nil {
+			return "", "", fmt.Errorf("Could not list the tags on remote %s: %w", remote.Config().Name, err)
+		}
+
+		for _, ref := range refs {
+			for _, tag := range tags {
+				if ref.Name() == plumbing.NewTagReferenceName(tag) {
+					return tag, remote.Config().Name, nil
+				}
+			}
+		}
+	}
+
+	return "", "", nil
+}
+
+// trackedFiles lists the files in the index, with paths relative to the top of the repository.
+func trackedFiles() ([]string, error) {
+	index, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(index.Entries))
+	for _, entry := range index.Entries {
+		files = append(files, entry.Name)
+	}
+	return files, nil
+}
+
+// shortHash abbreviates a hash the way git log --oneline does.
+func shortHash(hash plumbing.Hash) string {
+	const shortHashLength = 7
+	return hash.String()[:shortHashLength]
+}
+
+// commitsSince returns the commits reachable from HEAD that are not reachable from the given commit.
+//
+// If the given hash is the zero hash, all commits reachable from HEAD are returned.
+func commitsSince(since plumbing.Hash) ([]*object.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	return commitsBetween(since, head.Hash())
+}
+
+// commitsBetween returns the commits reachable from one commit that are not reachable from another,
+// like git log from..to does.
+func commitsBetween(from, to plumbing.Hash) ([]*object.Commit, error) {
+	seen := make(map[plumbing.Hash]bool)
+	if !from.IsZero() {
+		iter, err := repo.Log(&git.LogOptions{From: from})
+		if err != nil {
+			return nil, err
+		}
+
+		err = iter.ForEach(func(commit *object.Commit) error {
+			seen[commit.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: to})
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if !seen[commit.Hash] {
+			commits = append(commits, commit)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// commitFiles lists the files a commit changes, compared to its first parent.
+func commitFiles(commit *object.Commit) ([]string, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := &object.Tree{}
+	if commit.NumParents() != 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+	return files, nil
+}
+
+// doTagging applies a new tag, with the tag prefix added, to the repository and pushes to all remotes.
+//
+// If tags are annotated, the message is used as the annotation.
+func doTagging(version string, head plumbing.Hash, message string, dryrun bool) error {
+	tag := tagPrefix() + version
+	err := confirmTagging(fmt.Sprintf("Creating tag %s for version %s. Continue?", tag, version), dryrun)
+	if err != nil {
+		return err
+	}
+
+	return createTag(tag, version, head, message)
+}
+
+// confirmTagging asks whether to go ahead with tagging, and cancels it on a dry-run.
+func confirmTagging(label string, dryrun bool) error {
 	prompt := promptui.Prompt{
-		Label:     fmt.Sprintf("Creating tag for version %s. Continue?", tag),
+		Label:     label,
 		IsConfirm: true,
 	}
 
@@ -101,7 +274,27 @@ func doTagging(tag string, head plumbing.Hash, dryrun bool) error {
 		return errors.New("Tagging cancelled due to dry-run")
 	}
 
-	_, err = repo.CreateTag(tag, head, nil)
+	return nil
+}
+
+// createTag creates a tag for a version and pushes to all remotes.
+//
+// The alias tags of the version are moved to it afterwards.
+func createTag(tag, version string, head plumbing.Hash, message string) error {
+	aliases, err := aliasTagNames(strings.TrimSuffix(tag, version), version)
+	if err != nil {
+		return err
+	}
+
+	var opts *git.CreateTagOptions
+	if viper.GetBool("tag_annotated") {
+		if message == "" {
+			message = "Version " + version
+		}
+		opts = &git.CreateTagOptions{Message: message}
+	}
+
+	_, err = repo.CreateTag(tag, head, opts)
 	if err != nil {
 		return err
 	}
@@ -123,10 +316,18 @@ func doTagging(tag string, head plumbing.Hash, dryrun bool) error {
 		}
 	}
 
-	return nil
+	return moveAliasTags(aliases, head)
 }
 
-func updateFiles(version string, filesToProcess []string, dryrun bool) error {
+// versionCommitPrefix starts the message of the commits updating the version files.
+const versionCommitPrefix = "chore: Updating version to "
+
+// releaseStep makes further changes to the repository that go into the version commit.
+//
+// It returns whether anything was staged.
+type releaseStep func(worktree *git.Worktree) (bool, error)
+
+func updateFiles(version string, filesToProcess []versionFile, dryrun bool, steps ...releaseStep) error {
 	if dryrun {
 		return nil
 	}
@@ -136,20 +337,35 @@ func updateFiles(version string, filesToProcess []string, dryrun bool) error {
 		return err
 	}
 
-	for _, file := range filesToProcess {
-		err = replaceInFile(file, version)
+	// Every file is checked before any are written.
+	updates, err := planFileUpdates(filesToProcess, version)
+	if err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		err = update.write()
+		if err != nil {
+			return err
+		}
+
+		_, err = worktree.Add(update.path)
 		if err != nil {
 			return err
 		}
+	}
 
-		_, err = worktree.Add(file)
+	staged := len(updates) != 0
+	for _, step := range steps {
+		changed, err := step(worktree)
 		if err != nil {
 			return err
 		}
+		staged = staged || changed
 	}
 
-	if len(filesToProcess) != 0 {
-		_, err = worktree.Commit("chore: Updating version to "+version, &git.CommitOptions{
+	if staged {
+		_, err = worktree.Commit(versionCommitPrefix+version, &git.CommitOptions{
 			Amend:             false,
 			All:               false,
 			AllowEmptyCommits: false,
@@ -0,0 +1,267 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// readGoMod reads and parses the go.mod of the module in a directory, relative to the top of the repository.
+//
+// It returns nil if the directory is not a Go module.
+func readGoMod(modDir string) (*modfile.File, error) {
+	file := path.Join(modDir, "go.mod")
+	data, err := os.ReadFile(filepath.Join(gitDir, file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil //nolint:nilnil // Not being a Go module is not an error.
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read file %s: %w", file, err)
+	}
+
+	modFile, err := modfile.Parse(file, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return modFile, nil
+}
+
+// goMajorVersionStep moves a Go module to the module path a new major version needs, like example.com/thing/v2,
+// and rewrites the imports of its own packages to match.
+func goMajorVersionStep(modDir string, pvNext *semver.ParsedVersion) releaseStep {
+	return func(worktree *git.Worktree) (bool, error) {
+		modFile, err := readGoMod(modDir)
+		if err != nil || modFile == nil || modFile.Module == nil {
+			return false, err
+		}
+
+		oldPath := modFile.Module.Mod.Path
+		prefix, pathMajor, ok := module.SplitPathVersion(oldPath)
+		if !ok || strings.HasPrefix(oldPath, "gopkg.in/") {
+			return false, fmt.Errorf("Cannot work out the major version of module path %s", oldPath)
+		}
+
+		major := newVersionData(pvNext).Major
+		if major < 2 || pathMajor == fmt.Sprintf("/v%d", major) {
+			return false, nil
+		}
+		newPath := fmt.Sprintf("%s/v%d", prefix, major)
+
+		err = modFile.AddModuleStmt(newPath)
+		if err != nil {
+			return false, err
+		}
+		data, err := modFile.Format()
+		if err != nil {
+			return false, err
+		}
+
+		file := path.Join(modDir, "go.mod")
+		err = writeStaged(worktree, file, data)
+		if err != nil {
+			return false, err
+		}
+
+		files, err := goFilesInModule(modDir)
+		if err != nil {
+			return false, err
+		}
+
+		// Nested modules keep their own module paths, even when they start with this one.
+		nested, err := nestedModules(modDir)
+		if err != nil {
+			return false, err
+		}
+		nestedPaths := make([]string, 0, len(nested))
+		for _, nestedPath := range nested {
+			nestedPaths = append(nestedPaths, nestedPath)
+		}
+
+		for _, file := range files {
+			input, err := os.ReadFile(filepath.Join(gitDir, file))
+			if err != nil {
+				return false, fmt.Errorf("Could not read file %s: %w", file, err)
+			}
+
+			output, err := rewriteImports(file, input, oldPath, newPath, nestedPaths)
+			if err != nil {
+				return false, err
+			}
+			if output == nil {
+				continue
+			}
+
+			err = writeStaged(worktree, file, output)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		slog.Info(fmt.Sprintf("Moved module %s to %s", oldPath, newPath))
+		return true, nil
+	}
+}
+
+// writeStaged writes a file, relative to the top of the repository, keeping its permissions, and stages it.
+func writeStaged(worktree *git.Worktree, file string, data []byte) error {
+	fileName := filepath.Join(gitDir, file)
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		return fmt.Errorf("Could not get information about file %s: %w", file, err)
+	}
+
+	err = writeFileAtomic(fileName, data, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = worktree.Add(file)
+	return err
+}
+
+// nestedModules finds the modules nested in a module, from the go.mod files tracked in the repository.
+//
+// It returns the module path of each, by its directory.
+func nestedModules(modDir string) (map[string]string, error) {
+	tracked, err := trackedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	nested := make(map[string]string)
+	for _, file := range tracked {
+		dir := path.Dir(file)
+		if path.Base(file) != "go.mod" || dir == modDir || !inDir(dir, modDir) {
+			continue
+		}
+
+		modFile, err := readGoMod(dir)
+		if err != nil {
+			return nil, err
+		}
+		nested[dir] = ""
+		if modFile != nil && modFile.Module != nil {
+			nested[dir] = modFile.Module.Mod.Path
+		}
+	}
+
+	return nested, nil
+}
+
+// goFilesInModule lists the tracked Go files of a module,
+// leaving out those in nested modules, vendor and testdata directories.
+func goFilesInModule(modDir string) ([]string, error) {
+	tracked, err := trackedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	nested, err := nestedModules(modDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range tracked {
+		if !strings.HasSuffix(file, ".go") || !inDir(file, modDir) {
+			continue
+		}
+
+		skip := false
+		for dir := range nested {
+			skip = skip || inDir(file, dir)
+		}
+		for _, part := range strings.Split(path.Dir(file), "/") {
+			skip = skip || part == "vendor" || part == "testdata"
+		}
+		if !skip {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// inDir checks whether a path, relative to the top of the repository, is within a directory.
+func inDir(file, dir string) bool {
+	return dir == "." || file == dir || strings.HasPrefix(file, dir+"/")
+}
+
+// rewriteImports changes imports of a module path, and its packages, to another module path.
+//
+// Imports of the nested module paths, and their packages, are left alone, as they are in other modules.
+// Only the import paths themselves are rewritten, so the rest of the file is left as it was.
+// It returns nil if nothing was changed.
+func rewriteImports(file string, src []byte, oldPath, newPath string, nestedPaths []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %w", file, err)
+	}
+
+	var output []byte
+	last := 0
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read an import in %s: %w", file, err)
+		}
+		if !inModulePath(importPath, oldPath) ||
+			slices.ContainsFunc(nestedPaths, func(nestedPath string) bool { return inModulePath(importPath, nestedPath) }) {
+			continue
+		}
+
+		start := fset.Position(spec.Path.Pos()).Offset
+		end := fset.Position(spec.Path.End()).Offset
+		output = append(output, src[last:start]...)
+		output = append(output, strconv.Quote(newPath+strings.TrimPrefix(importPath, oldPath))...)
+		last = end
+	}
+
+	if output == nil {
+		return nil, nil
+	}
+	return append(output, src[last:]...), nil
+}
+
+// inModulePath checks whether an import path is a module path or one of its packages.
+func inModulePath(importPath, modPath string) bool {
+	return modPath != "" && (importPath == modPath || strings.HasPrefix(importPath, modPath+"/"))
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRewriteImports(t *testing.T) {
+	src := `package thing
+
+import (
+	"fmt"
+
+	"example.com/m"
+	"example.com/m/pkg"
+	"example.com/m/sub"
+	"example.com/m/sub/pkg"
+	"example.com/more"
+)
+`
+	expected := `package thing
+
+import (
+	"fmt"
+
+	"example.com/m/v2"
+	"example.com/m/v2/pkg"
+	"example.com/m/sub"
+	"example.com/m/sub/pkg"
+	"example.com/more"
+)
+`
+
+	output, err := rewriteImports("thing.go", []byte(src), "example.com/m", "example.com/m/v2",
+		[]string{"example.com/m/sub"})
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if diff := cmp.Diff(expected, string(output)); diff != "" {
+		t.Error(diff)
+	}
+
+	output, err = rewriteImports("thing.go", []byte("package thing\n\nimport \"fmt\"\n"),
+		"example.com/m", "example.com/m/v2", nil)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if output != nil {
+		t.Error("incorrect result: expected nil, got", string(output))
+	}
+
+	_, err = rewriteImports("broken.go", []byte("package"), "example.com/m", "example.com/m/v2", nil)
+	if err == nil {
+		t.Error(ExpectError)
+	}
+}
+
+func TestGoFilesInModule(t *testing.T) {
+	useTestRepo(t, map[string]string{
+		"go.mod":                "module example.com/m\n",
+		"main.go":               "package main\n",
+		"pkg/pkg.go":            "package pkg\n",
+		"pkg/README.md":         "Not Go\n",
+		"pkg/testdata/data.go":  "package data\n",
+		"vendor/other/other.go": "package other\n",
+		"sub/go.mod":            "module example.com/m/sub\n",
+		"sub/sub.go":            "package sub\n",
+	})
+
+	files, err := goFilesInModule(".")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	slices.Sort(files)
+	if diff := cmp.Diff([]string{"main.go", "pkg/pkg.go"}, files); diff != "" {
+		t.Error(diff)
+	}
+
+	files, err = goFilesInModule("sub")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if diff := cmp.Diff([]string{"sub/sub.go"}, files); diff != "" {
+		t.Error(diff)
+	}
+
+	nested, err := nestedModules(".")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if diff := cmp.Diff(map[string]string{"sub": "example.com/m/sub"}, nested); diff != "" {
+		t.Error(diff)
+	}
+}
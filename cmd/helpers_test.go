@@ -0,0 +1,126 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	ExpectError    = "Did not get error when expected"
+	ExpectNilError = "Got error"
+)
+
+// useTestRepo makes the globals point at a new repository with the files given staged in it,
+// and puts them back after the test.
+func useTestRepo(t *testing.T, files map[string]string) *git.Worktree {
+	t.Helper()
+
+	previousRepo, previousGitDir := repo, gitDir
+	t.Cleanup(func() { repo, gitDir = previousRepo, previousGitDir })
+
+	gitDir = t.TempDir()
+	var err error
+	repo, err = git.PlainInit(gitDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		fileName := filepath.Join(gitDir, name)
+		//revive:disable-next-line:add-constant
+		err := os.MkdirAll(filepath.Dir(fileName), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//revive:disable-next-line:add-constant
+		err = os.WriteFile(fileName, []byte(contents), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = worktree.Add(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return worktree
+}
+
+// testCommit writes the files given in the test repository and commits them.
+func testCommit(t *testing.T, worktree *git.Worktree, message string, files map[string]string) plumbing.Hash {
+	t.Helper()
+
+	for name, contents := range files {
+		fileName := filepath.Join(gitDir, name)
+		//revive:disable-next-line:add-constant
+		err := os.MkdirAll(filepath.Dir(fileName), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//revive:disable-next-line:add-constant
+		err = os.WriteFile(fileName, []byte(contents), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = worktree.Add(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := worktree.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// testCheckout points HEAD at a branch, which is created at a commit.
+func testCheckout(t *testing.T, branch string, hash plumbing.Hash) {
+	t.Helper()
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	err := repo.Storer.SetReference(plumbing.NewHashReference(ref, hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// hookMarker identifies hooks that install-hooks wrote, so they can be replaced.
+	hookMarker = "# Installed by git-next-tag install-hooks."
+
+	commitMsgHook = `#!/bin/sh
+` + hookMarker + `
+exec git next-tag lint --message-file "$1"
+`
+
+	// hookPerm is the permission hooks need to be run by git.
+	hookPerm fs.FileMode = 0o755
+)
+
+var installHooksCmd = &cobra.Command{
+	Use:     "install-hooks",
+	Short:   "Install a commit-msg hook that lints commit messages.",
+	Long:    `Install a commit-msg hook in .git/hooks that runs git next-tag lint on every commit message.`,
+	Args:    cobra.NoArgs,
+	PreRunE: func(_ *cobra.Command, _ []string) error { _, err := loadConfig(); return err },
+	RunE:    installHooks,
+}
+
+func init() {
+	installHooksCmd.Flags().Bool("force", false, "Replace a commit-msg hook that git-next-tag did not install")
+	rootCmd.AddCommand(installHooksCmd)
+}
+
+// installHooks writes the commit-msg hook.
+func installHooks(cmd *cobra.Command, _ []string) error {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return errors.New("git not running on a filesystem?")
+	}
+
+	hooksDir := filepath.Join(storage.Filesystem().Root(), "hooks")
+	err := os.MkdirAll(hooksDir, hookPerm)
+	if err != nil {
+		return fmt.Errorf("Could not create directory %s: %w", hooksDir, err)
+	}
+
+	hook := filepath.Join(hooksDir, "commit-msg")
+	force, _ := cmd.Flags().GetBool("force")
+	existing, err := os.ReadFile(hook)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("Could not read file %s: %w", hook, err)
+	case !force && !strings.Contains(string(existing), hookMarker):
+		return fmt.Errorf("%s already exists, use --force to replace it", hook)
+	}
+
+	//nolint:gosec // Hooks have to be executable.
+	err = os.WriteFile(hook, []byte(commitMsgHook), hookPerm)
+	if err != nil {
+		return fmt.Errorf("Could not write to file %s: %w", hook, err)
+	}
+	// WriteFile does not change the permissions of a file that already exists.
+	err = os.Chmod(hook, hookPerm)
+	if err != nil {
+		return fmt.Errorf("Could not make %s executable: %w", hook, err)
+	}
+
+	cmd.Println("Installed " + hook)
+	return nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/mod/modfile"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the configuration, and optionally a version.go file.",
+	Long: `Create the .git-next-tag configuration file if there is none.
+
+With --go-version-file, also generate a version.go file in that package directory
+at the current version, and add it to version_files.`,
+	Args:    cobra.NoArgs,
+	PreRunE: func(_ *cobra.Command, _ []string) error { return initConfig() },
+	RunE:    initRepository,
+}
+
+func init() {
+	initCmd.Flags().String("go-version-file", "", "Generate a version.go file in this package directory")
+	initCmd.Flags().Bool("ldflags-vars", false, "Also generate Commit and Date variables to set with -ldflags")
+	rootCmd.AddCommand(initCmd)
+}
+
+// initRepository generates the version.go file asked for.
+func initRepository(cmd *cobra.Command, _ []string) error {
+	pkgDir, _ := cmd.Flags().GetString("go-version-file")
+	if pkgDir == "" {
+		return nil
+	}
+	pkgDir = path.Clean(filepath.ToSlash(pkgDir))
+	ldflagsVars, _ := cmd.Flags().GetBool("ldflags-vars")
+
+	file := path.Join(pkgDir, "version.go")
+	fileName := filepath.Join(gitDir, file)
+	if _, err := os.Stat(fileName); err == nil {
+		return fmt.Errorf("%s already exists", file)
+	}
+
+	pkg, err := packageName(filepath.Join(gitDir, pkgDir))
+	if err != nil {
+		return err
+	}
+
+	version, err := currentVersion()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fileName), dirPerm)
+	if err != nil {
+		return fmt.Errorf("Could not create directory %s: %w", pkgDir, err)
+	}
+
+	err = createVersionDotGoFile(pkg, fileName, version, ldflagsVars)
+	if err != nil {
+		return err
+	}
+
+	files, _ := viper.Get("version_files").([]any)
+	if !slices.Contains(files, any(file)) {
+		viper.Set("version_files", append(files, file))
+	}
+	viper.Set("go_version_file", file)
+	viper.Set("go_version_ldflags", ldflagsVars)
+
+	err = viper.WriteConfig()
+	if err != nil {
+		return fmt.Errorf("Could not save configuration: %w", err)
+	}
+
+	cmd.Printf("Created %s at version %s\n", file, version)
+	return nil
+}
+
+// dirPerm is the permission used for directories that need created.
+const dirPerm fs.FileMode = 0o755
+
+// currentVersion is the version the version files should have now,
+// or the version before the first tag if there are no tags yet.
+func currentVersion() (string, error) {
+	tags, err := retrieveTags()
+	if err != nil {
+		return "", err
+	}
+
+	tagVersions, _ := sortTagVersions(tags)
+	if len(tagVersions) != 0 {
+		return expectedFileVersion(tagVersions[0])
+	}
+
+	if viper.GetBool("always_leave_version_pre") {
+		return normalizeVersion("0.1.0-pre"), nil
+	}
+	return normalizeVersion("0.1.0"), nil
+}
+
+// packageName finds the name of the Go package in a directory.
+//
+// If the directory has no Go files yet, it is named after the directory.
+func packageName(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, match := range matches {
+		if strings.HasSuffix(match, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), match, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return "", fmt.Errorf("Could not parse %s: %w", match, err)
+		}
+		return f.Name.Name, nil
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return strings.NewReplacer("-", "_", ".", "_").Replace(filepath.Base(abs)), nil
+}
+
+// findGoMod finds the go.mod of the module a directory is in, without leaving the repository.
+//
+// It returns the directory of the module, and its parsed go.mod.
+func findGoMod(dir string) (string, *modfile.File, error) {
+	for {
+		fileName := filepath.Join(dir, "go.mod")
+		data, err := os.ReadFile(fileName)
+		if err == nil {
+			modFile, err := modfile.Parse(fileName, data, nil)
+			if err != nil {
+				return "", nil, err
+			}
+			return dir, modFile, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, fmt.Errorf("Could not read file %s: %w", fileName, err)
+		}
+
+		if rel, err := filepath.Rel(gitDir, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return "", nil, fmt.Errorf("%s is not in a Go module", dir)
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ldflagsCmd = &cobra.Command{
+	Use:   "ldflags",
+	Short: "Print the -ldflags -X flags that set the version variables.",
+	Long: `Print the -X flags for go build -ldflags that set Version, Commit and Date
+in the version.go file generated by git next-tag init --go-version-file --ldflags-vars.
+
+Version is the tag on HEAD if there is one, otherwise the version in the version files.
+Commit is the hash of HEAD, and Date is when HEAD was committed, so builds are reproducible.`,
+	Args:    cobra.NoArgs,
+	PreRunE: func(_ *cobra.Command, _ []string) error { _, err := loadConfig(); return err },
+	RunE:    ldflags,
+}
+
+func init() {
+	rootCmd.AddCommand(ldflagsCmd)
+}
+
+// ldflags prints the -X flags for the generated version.go file.
+func ldflags(cmd *cobra.Command, _ []string) error {
+	file := viper.GetString("go_version_file")
+	if file == "" || !viper.GetBool("go_version_ldflags") {
+		return errors.New("No version.go was generated with git next-tag init --go-version-file --ldflags-vars")
+	}
+
+	importPath, err := linkerPackagePath(filepath.Join(gitDir, path.Dir(file)))
+	if err != nil {
+		return err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	version, err := headVersion(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	flags := make([]string, 0, 3)
+	for _, variable := range [][2]string{
+		{"Version", version},
+		{"Commit", commit.Hash.String()},
+		{"Date", commit.Committer.When.UTC().Format(time.RFC3339)},
+	} {
+		flags = append(flags, fmt.Sprintf("-X '%s.%s=%s'", importPath, variable[0], variable[1]))
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), strings.Join(flags, " "))
+	return nil
+}
+
+// linkerPackagePath is the package path -X needs for the variables of the package in a directory:
+// its import path, or main for a command, as the linker only knows a main package by that name.
+func linkerPackagePath(pkgDir string) (string, error) {
+	name, err := packageName(pkgDir)
+	if err != nil {
+		return "", err
+	}
+	if name == "main" {
+		return name, nil
+	}
+
+	modDir, modFile, err := findGoMod(pkgDir)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(modDir, pkgDir)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(modFile.Module.Mod.Path, filepath.ToSlash(rel)), nil
+}
+
+// headVersion is the version tag on HEAD if there is one,
+// otherwise the version the version files should have.
+func headVersion(head plumbing.Hash) (string, error) {
+	tags, err := retrieveTags()
+	if err != nil {
+		return "", err
+	}
+
+	tagVersions, tagNames := sortTagVersions(tags)
+	for _, pv := range tagVersions {
+		if tags[tagNames[pv]] == head {
+			return normalizeVersion(pv.String()), nil
+		}
+	}
+
+	return currentVersion()
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkerPackagePath(t *testing.T) {
+	useTestRepo(t, map[string]string{
+		"go.mod":                     "module example.com/m\n",
+		"version.go":                 "package m\n",
+		"cmd/thing/main.go":          "package main\n\nfunc main() {}\n",
+		"cmd/thing/version.go":       "package main\n",
+		"internal/version/doc.go":    "package version\n",
+		"sub/go.mod":                 "module example.com/sub\n",
+		"sub/pkg/version/version.go": "package version\n",
+	})
+
+	tests := map[string]string{
+		".":                "example.com/m",
+		"cmd/thing":        "main",
+		"internal/version": "example.com/m/internal/version",
+		"sub/pkg/version":  "example.com/sub/pkg/version",
+	}
+	for dir, expected := range tests {
+		got, err := linkerPackagePath(filepath.Join(gitDir, dir))
+		if err != nil {
+			t.Error(dir, ExpectNilError, err)
+		}
+		if got != expected {
+			t.Errorf("incorrect result for %s: expected %s, got %s", dir, expected, got)
+		}
+	}
+}
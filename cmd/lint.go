@@ -0,0 +1,191 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/cobra"
+)
+
+// scissorsRegexp matches the line git commit --verbose puts above the diff.
+var scissorsRegexp = regexp.MustCompile(`(?m)^# -+ >8 -+\n(?s:.*)`)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [range]",
+	Short: "Check commit messages against Conventional Commits.",
+	Long: `Check commit messages against Conventional Commits and the configured commit_types.
+
+The range is given like git log takes it, like v1.2.0..HEAD. A single revision checks just that commit.
+Without a range, the commits since the latest version tag are checked.`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: func(_ *cobra.Command, _ []string) error { _, err := loadConfig(); return err },
+	RunE:    lint,
+}
+
+func init() {
+	lintCmd.Flags().String("message-file", "", "Check the commit message in this file, as a commit-msg hook does")
+	rootCmd.AddCommand(lintCmd)
+}
+
+// lint checks the commit messages asked for.
+func lint(cmd *cobra.Command, args []string) error {
+	types := commitTypes()
+
+	messageFile, _ := cmd.Flags().GetString("message-file")
+	if messageFile != "" {
+		input, err := os.ReadFile(messageFile)
+		if err != nil {
+			return fmt.Errorf("Could not read file %s: %w", messageFile, err)
+		}
+
+		// The hook also runs for the messages git writes itself.
+		message := cleanMessage(string(input))
+		if isAutosquash(message) || isMergeOrRevert(message) {
+			return nil
+		}
+
+		_, errs := parseConventionalCommit(message, types)
+		for _, err := range errs {
+			cmd.PrintErrln(messageFile + ":" + err.Error())
+		}
+		if len(errs) != 0 {
+			return errors.New("The commit message does not follow Conventional Commits")
+		}
+		return nil
+	}
+
+	commits, err := lintRange(args)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, commit := range commits {
+		// Merge commits and commits that will be squashed away are written by git.
+		if commit.NumParents() > 1 || isAutosquash(commit.Message) || isMergeOrRevert(commit.Message) {
+			continue
+		}
+
+		_, errs := parseConventionalCommit(commit.Message, types)
+		for _, err := range errs {
+			cmd.PrintErrln(shortHash(commit.Hash) + ":" + err.Error())
+		}
+		if len(errs) != 0 {
+			failed++
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d commit messages do not follow Conventional Commits", failed, len(commits))
+	}
+	return nil
+}
+
+// lintRange finds the commits in the range given, or the commits since the latest version tag.
+func lintRange(args []string) ([]*object.Commit, error) {
+	if len(args) == 0 {
+		tags, err := retrieveTags()
+		if err != nil {
+			return nil, err
+		}
+
+		_, since := latestVersionTag(tags)
+		return commitsSince(since)
+	}
+
+	from, to, isRange := strings.Cut(args[0], "..")
+	if !isRange {
+		hash, err := repo.ResolveRevision(plumbing.Revision(args[0]))
+		if err != nil {
+			return nil, fmt.Errorf("Could not find revision %s: %w", args[0], err)
+		}
+
+		commit, err := repo.CommitObject(*hash)
+		if err != nil {
+			return nil, err
+		}
+		return []*object.Commit{commit}, nil
+	}
+
+	// Like git, an empty side of the range means HEAD.
+	hashes := make([]plumbing.Hash, 0, 2)
+	for _, rev := range []string{from, to} {
+		if rev == "" {
+			rev = "HEAD"
+		}
+		hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return nil, fmt.Errorf("Could not find revision %s: %w", rev, err)
+		}
+		hashes = append(hashes, *hash)
+	}
+
+	return commitsBetween(hashes[0], hashes[1])
+}
+
+// cleanMessage removes what git strips from a commit message file before committing it.
+func cleanMessage(message string) string {
+	message = scissorsRegexp.ReplaceAllString(message, "")
+
+	lines := strings.Split(message, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			kept = append(kept, line)
+		}
+	}
+
+	return strings.TrimLeft(strings.Join(kept, "\n"), "\n")
+}
+
+// isAutosquash checks for commits made by git commit --fixup and --squash.
+func isAutosquash(message string) bool {
+	for _, prefix := range []string{"fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMergeOrRevert checks for the messages git merge and git revert write by default,
+// like Merge branch 'topic' and Revert "feat: thing".
+func isMergeOrRevert(message string) bool {
+	for _, prefix := range []string{
+		"Merge branch ", "Merge branches ", "Merge remote-tracking branch ", "Merge tag ", "Merge commit ",
+		"Revert \"",
+	} {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
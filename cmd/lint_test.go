@@ -0,0 +1,69 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestLintMessageFile(t *testing.T) {
+	tests := map[string]bool{
+		"feat: thing\n":          true,
+		"thing\n":                false,
+		"Merge branch 'topic'\n": true,
+		"Merge remote-tracking branch 'origin/main'\n":         true,
+		"Merge tag 'v1.2.0'\n":                                 true,
+		"fixup! feat: thing\n":                                 true,
+		"squash! feat: thing\n":                                true,
+		"Revert \"feat: thing\"\n\nThis reverts commit abc.\n": true,
+		"Reverted the thing\n":                                 false,
+		"# Please enter the commit message\nfix: thing\n":      true,
+	}
+
+	messageFile := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	for message, valid := range tests {
+		//revive:disable-next-line:add-constant
+		err := os.WriteFile(messageFile, []byte(message), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := &cobra.Command{}
+		cmd.Flags().String("message-file", messageFile, "")
+		cmd.SetErr(&bytes.Buffer{})
+
+		err = lint(cmd, nil)
+		if valid && err != nil {
+			t.Errorf("incorrect result for %q: %s %v", message, ExpectNilError, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("incorrect result for %q: %s", message, ExpectError)
+		}
+	}
+}
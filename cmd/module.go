@@ -0,0 +1,119 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/viper"
+)
+
+// moduleDir is the directory of the module being released, relative to the top of the repository,
+// or "." when the whole repository is.
+func moduleDir() string {
+	dir := strings.TrimPrefix(path.Clean("/"+viper.GetString("module")), "/")
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// tagPrefix is put in front of the version in the name of every tag, like "semver/" for the module in semver/.
+//
+// It defaults to the directory of the module being released, as Go expects for nested modules.
+func tagPrefix() string {
+	if viper.IsSet("tag_prefix") {
+		return viper.GetString("tag_prefix")
+	}
+
+	return moduleTagPrefix(moduleDir())
+}
+
+// useModule makes dir the module being released, and returns a function that puts back the one before it.
+func useModule(dir string) func() {
+	saved := viper.Get("module")
+	viper.Set("module", dir)
+	return func() { viper.Set("module", saved) }
+}
+
+// moduleTagPrefix is the tag prefix Go expects for the module in a directory.
+func moduleTagPrefix(dir string) string {
+	if dir == "." {
+		return ""
+	}
+	return dir + "/"
+}
+
+// moduleFileFilter finds which files belong to the module being released, leaving out the modules nested in it.
+//
+// Without a module, every file belongs to the release.
+func moduleFileFilter() (func(file string) bool, error) {
+	if viper.GetString("module") == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	dir := moduleDir()
+	nested, err := nestedModules(dir)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{dir}
+	for nestedDir := range nested {
+		dirs = append(dirs, nestedDir)
+	}
+
+	return func(file string) bool { return owningModule(file, dirs) == dir }, nil
+}
+
+// moduleCommits keeps the commits that change a file of the module being released.
+func moduleCommits(commits []*object.Commit) ([]*object.Commit, error) {
+	if viper.GetString("module") == "" {
+		return commits, nil
+	}
+
+	inModule, err := moduleFileFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*object.Commit
+	for _, commit := range commits {
+		files, err := commitFiles(commit)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(files, inModule) {
+			changes = append(changes, commit)
+		}
+	}
+	return changes, nil
+}
+
+// modulePath puts a path from the changelog or render_files settings in the directory of the module being released.
+func modulePath(file string) string {
+	return path.Join(moduleDir(), file)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/viper"
+)
+
+func TestModuleCommits(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod":         "module example.com/m\n",
+		"lib/go.mod":     "module example.com/m/lib\n",
+		"lib/sub/go.mod": "module example.com/m/lib/sub\n",
+	})
+	first := testCommit(t, worktree, "chore: init", nil)
+	root := testCommit(t, worktree, "fix: root", map[string]string{"root.go": "package m\n"})
+	lib := testCommit(t, worktree, "fix: lib", map[string]string{"lib/lib.go": "package lib\n"})
+	sub := testCommit(t, worktree, "fix: sub", map[string]string{"lib/sub/sub.go": "package sub\n"})
+
+	commits, err := commitsSince(plumbing.ZeroHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { viper.Set("module", nil) })
+	tests := map[string][]plumbing.Hash{
+		"":        {first, root, lib, sub},
+		".":       {first, root},
+		"lib":     {first, lib},
+		"lib/sub": {first, sub},
+	}
+	for module, expected := range tests {
+		viper.Set("module", module)
+		changes, err := moduleCommits(commits)
+		if err != nil {
+			t.Error(module, ExpectNilError, err)
+		}
+
+		found := make(map[plumbing.Hash]bool, len(changes))
+		for _, commit := range changes {
+			found[commit.Hash] = true
+		}
+		if len(found) != len(expected) {
+			t.Errorf("incorrect result for module %q: expected %d commits, got %d", module, len(expected), len(found))
+		}
+		for _, hash := range expected {
+			if !found[hash] {
+				t.Errorf("incorrect result for module %q: expected commit %s", module, hash)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+// referenceLink turns references matching a pattern, like #123 or JIRA-42, into links.
+type referenceLink struct {
+	Pattern string `mapstructure:"pattern"`
+	URL     string `mapstructure:"url"`
+
+	regexp   *regexp.Regexp
+	template *template.Template
+}
+
+// referenceData is what a reference link URL template is rendered with.
+type referenceData struct {
+	// Repository is the web address of the repository, worked out from the URL of its remote.
+	Repository string
+	// ID is the first group captured by the pattern, or the whole reference without one.
+	ID string
+	// Reference is the whole reference.
+	Reference string
+}
+
+// linker turns references in release notes into markdown links.
+type linker struct {
+	repository string
+	links      []referenceLink
+}
+
+// scpLikeRegexp matches remote URLs like git@github.com:owner/repo.git.
+var scpLikeRegexp = regexp.MustCompile(`\A(?:[^@/]+@)?([^:/]+):(.+)\z`)
+
+// newLinker reads the reference_links setting.
+//
+// Without one, #123 is linked to the issues of the repository, if the remote is on a known forge.
+func newLinker() (*linker, error) {
+	lnk := linker{repository: repositoryURL()}
+
+	if viper.IsSet("reference_links") {
+		err := viper.UnmarshalKey("reference_links", &lnk.links)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read reference_links: %w", err)
+		}
+	} else if lnk.repository != "" {
+		issues := "/issues/"
+		if strings.Contains(lnk.repository, "gitlab") {
+			issues = "/-/issues/"
+		}
+		lnk.links = []referenceLink{{Pattern: `#(\d+)`, URL: "{{.Repository}}" + issues + "{{.ID}}"}}
+	}
+
+	for i := range lnk.links {
+		link := &lnk.links[i]
+
+		rx, err := regexp.Compile(link.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Could not compile reference_links pattern %s: %w", link.Pattern, err)
+		}
+		link.regexp = rx
+
+		tmpl, err := template.New(link.Pattern).Option("missingkey=error").Parse(link.URL)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse reference_links URL %s: %w", link.URL, err)
+		}
+		link.template = tmpl
+	}
+
+	return &lnk, nil
+}
+
+// repositoryURL works out the web address of the repository from its origin remote,
+// or its first remote if there is no origin.
+func repositoryURL() string {
+	remotes, err := repo.Remotes()
+	if err != nil || len(remotes) == 0 {
+		return ""
+	}
+
+	remote := remotes[0]
+	for _, r := range remotes {
+		if r.Config().Name == "origin" {
+			remote = r
+		}
+	}
+
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return ""
+	}
+
+	return webURL(urls[0])
+}
+
+// webURL turns a git remote URL into the https address of the repository.
+func webURL(remoteURL string) string {
+	var host, repoPath string
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil || u.Host == "" {
+			return ""
+		}
+		host, repoPath = u.Hostname(), u.Path
+	} else {
+		matches := scpLikeRegexp.FindStringSubmatch(remoteURL)
+		if matches == nil {
+			return ""
+		}
+		host, repoPath = matches[1], matches[2]
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	return "https://" + host + "/" + repoPath
+}
+
+// reference is a reference found in text, along with where it should link to.
+type reference struct {
+	start int
+	end   int
+	url   string
+}
+
+// findReferences finds the references in the text, in order, without any overlapping each other.
+func (lnk *linker) findReferences(text string) ([]reference, error) {
+	var refs []reference
+	for _, link := range lnk.links {
+		for _, match := range link.regexp.FindAllStringSubmatchIndex(text, -1) {
+			data := referenceData{
+				Repository: lnk.repository,
+				ID:         text[match[0]:match[1]],
+				Reference:  text[match[0]:match[1]],
+			}
+			if len(match) > 2 && match[2] != -1 {
+				data.ID = text[match[2]:match[3]]
+			}
+
+			var sb strings.Builder
+			err := link.template.Execute(&sb, data)
+			if err != nil {
+				return nil, fmt.Errorf("Could not render reference_links URL %s: %w", link.URL, err)
+			}
+			refs = append(refs, reference{start: match[0], end: match[1], url: sb.String()})
+		}
+	}
+
+	slices.SortStableFunc(refs, func(a, b reference) int { return a.start - b.start })
+
+	kept := refs[:0]
+	for _, ref := range refs {
+		if len(kept) == 0 || ref.start >= kept[len(kept)-1].end {
+			kept = append(kept, ref)
+		}
+	}
+
+	return kept, nil
+}
+
+// link turns the references in the text into markdown links.
+func (lnk *linker) link(text string) (string, error) {
+	refs, err := lnk.findReferences(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, ref := range refs {
+		fmt.Fprintf(&sb, "%s[%s](%s)", text[last:ref.start], text[ref.start:ref.end], ref.url)
+		last = ref.end
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String(), nil
+}
+
+// linkAll returns links for every reference in the text, like for the body of a commit.
+func (lnk *linker) linkAll(text string) ([]string, error) {
+	refs, err := lnk.findReferences(text)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		link := fmt.Sprintf("[%s](%s)", text[ref.start:ref.end], ref.url)
+		if !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"regexp"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWebURL(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:owner/repo.git":                "https://github.com/owner/repo",
+		"github.com:owner/repo":                        "https://github.com/owner/repo",
+		"ssh://git@gitlab.com:2222/group/sub/repo.git": "https://gitlab.com/group/sub/repo",
+		"https://github.com/owner/repo.git":            "https://github.com/owner/repo",
+		"https://user@codeberg.org/owner/repo/":        "https://codeberg.org/owner/repo",
+		"file:///srv/git/repo.git":                     "",
+		"/srv/git/repo.git":                            "",
+	}
+
+	for remoteURL, expected := range tests {
+		if got := webURL(remoteURL); got != expected {
+			t.Errorf("incorrect result for %s: expected %q, got %q", remoteURL, expected, got)
+		}
+	}
+}
+
+// testLinker makes a linker for issues and pull requests of example.com/repo.
+func testLinker() *linker {
+	lnk := &linker{repository: "https://example.com/repo"}
+	for pattern, url := range map[string]string{
+		`#(\d+)`:    "{{.Repository}}/issues/{{.ID}}",
+		`PR #(\d+)`: "{{.Repository}}/pull/{{.ID}}",
+		`JIRA-\d+`:  "https://jira.example.com/browse/{{.Reference}}",
+	} {
+		lnk.links = append(lnk.links, referenceLink{
+			Pattern:  pattern,
+			URL:      url,
+			regexp:   regexp.MustCompile(pattern),
+			template: template.Must(template.New(pattern).Option("missingkey=error").Parse(url)),
+		})
+	}
+	return lnk
+}
+
+func TestFindReferences(t *testing.T) {
+	refs, err := testLinker().findReferences("See PR #12, #3 and JIRA-42.")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+
+	expected := []reference{
+		{start: 4, end: 10, url: "https://example.com/repo/pull/12"},
+		{start: 12, end: 14, url: "https://example.com/repo/issues/3"},
+		{start: 19, end: 26, url: "https://jira.example.com/browse/JIRA-42"},
+	}
+	if diff := cmp.Diff(expected, refs, cmp.AllowUnexported(reference{})); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLink(t *testing.T) {
+	tests := map[string]string{
+		"fix: thing (#12)": "fix: thing ([#12](https://example.com/repo/issues/12))",
+		"PR #7":            "[PR #7](https://example.com/repo/pull/7)",
+		"No references":    "No references",
+	}
+
+	lnk := testLinker()
+	for text, expected := range tests {
+		got, err := lnk.link(text)
+		if err != nil {
+			t.Error(ExpectNilError, err)
+		}
+		if got != expected {
+			t.Errorf("incorrect result for %q: expected %q, got %q", text, expected, got)
+		}
+	}
+}
+
+func TestLinkAll(t *testing.T) {
+	links, err := testLinker().linkAll("Fixes #3.\nRefs #3 and JIRA-42.\n")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+
+	expected := []string{
+		"[#3](https://example.com/repo/issues/3)",
+		"[JIRA-42](https://jira.example.com/browse/JIRA-42)",
+	}
+	if diff := cmp.Diff(expected, links); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRenderReleaseNotes(t *testing.T) {
+	useTestRepo(t, nil)
+	_, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"git@github.com:owner/repo.git"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(hash, message, author string, parents int) *object.Commit {
+		return &object.Commit{
+			Hash:         plumbing.NewHash(hash),
+			Message:      message,
+			Author:       object.Signature{Name: author},
+			ParentHashes: make([]plumbing.Hash, parents),
+		}
+	}
+	commits := []*object.Commit{
+		commit("1111111111111111111111111111111111111111", "feat: thing (#12)\n\nFixes #3.\nRefs #3.\n", "Zoe", 1),
+		commit("2222222222222222222222222222222222222222", "Merge branch 'topic'\n", "Zoe", 2),
+		commit("3333333333333333333333333333333333333333", "fixup! feat: thing\n", "Zoe", 1),
+		commit("4444444444444444444444444444444444444444", versionCommitPrefix+"v1.1.0\n", "Bot", 1),
+		commit("5555555555555555555555555555555555555555", "fix: other\n", "Adam", 1),
+		commit("6666666666666666666666666666666666666666", "docs: more\n", "Zoe", 1),
+	}
+	fragments := []changelogFragment{{file: "changes/12.feature.md", id: "12", kind: "feature", text: "A thing."}}
+
+	notes, err := renderReleaseNotes("v1.2.0", fragments, commits)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+
+	expected := "## v1.2.0 (" + time.Now().Format(time.DateOnly) + ")\n\n" +
+		"### Features\n\n- A thing. ([#12](https://github.com/owner/repo/issues/12))\n\n" +
+		"### Commits\n\n" +
+		"- feat: thing ([#12](https://github.com/owner/repo/issues/12)) " +
+		"(1111111, [#3](https://github.com/owner/repo/issues/3))\n" +
+		"- fix: other (5555555)\n" +
+		"- docs: more (6666666)\n\n" +
+		"### Contributors\n\n- Adam\n- Zoe\n"
+	if diff := cmp.Diff(expected, notes); diff != "" {
+		t.Error(diff)
+	}
+
+	notes, err = renderReleaseNotes("v1.2.0", nil, commits[1:4])
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if notes != "" {
+		t.Error("incorrect result: expected no notes without fragments or commits of note, got", notes)
+	}
+}
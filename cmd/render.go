@@ -0,0 +1,159 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/spf13/viper"
+)
+
+// renderFile is an entry of the render_files setting.
+type renderFile struct {
+	// Template is a text/template file, like VERSION.tmpl.
+	Template string `mapstructure:"template"`
+	// Output is the file it is rendered to, like VERSION.
+	Output string `mapstructure:"output"`
+
+	tmpl *template.Template
+}
+
+// renderData is what render_files templates are rendered with.
+type renderData struct {
+	// Version is the version being tagged, as it is tagged.
+	Version string
+	// Previous is the version tagged before it, or "" if there was none.
+	Previous string
+	// Date is the day of the release, like 2024-01-31.
+	Date string
+	// Base is the hash of the commit the release is made from.
+	// The version commit, which the tag is on, is made after rendering, so its hash cannot be known yet.
+	Base string
+}
+
+// getRenderFiles reads the render_files setting, and parses the templates.
+//
+// The templates and outputs are in the directory of the module being released.
+func getRenderFiles() ([]renderFile, error) {
+	var files []renderFile
+	err := viper.UnmarshalKey("render_files", &files)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read render_files: %w", err)
+	}
+
+	for i := range files {
+		file := &files[i]
+		if file.Template == "" || file.Output == "" {
+			return nil, errors.New("Every entry in render_files needs a template and an output")
+		}
+		file.Template, file.Output = modulePath(file.Template), modulePath(file.Output)
+
+		input, err := os.ReadFile(filepath.Join(gitDir, file.Template))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read file %s: %w", file.Template, err)
+		}
+
+		file.tmpl, err = template.New(file.Template).Option("missingkey=error").Parse(string(input))
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse template %s: %w", file.Template, err)
+		}
+	}
+
+	return files, nil
+}
+
+// renderStep renders the render_files templates for the version being tagged.
+func renderStep(files []renderFile, version, previous string) releaseStep {
+	return func(worktree *git.Worktree) (bool, error) {
+		if len(files) == 0 {
+			return false, nil
+		}
+
+		head, err := repo.Head()
+		if err != nil {
+			return false, err
+		}
+
+		data := renderData{
+			Version:  version,
+			Previous: previous,
+			Date:     time.Now().Format(time.DateOnly),
+			Base:     head.Hash().String(),
+		}
+
+		staged := false
+		for _, file := range files {
+			var output bytes.Buffer
+			err := file.tmpl.Execute(&output, data)
+			if err != nil {
+				return false, fmt.Errorf("Could not render template %s: %w", file.Template, err)
+			}
+
+			// The output keeps its own permissions, or takes the template's when it is new.
+			fileName := filepath.Join(gitDir, file.Output)
+			existing, err := os.ReadFile(fileName)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return false, fmt.Errorf("Could not read file %s: %w", file.Output, err)
+			}
+			if err == nil && bytes.Equal(existing, output.Bytes()) {
+				continue
+			}
+
+			permFrom := fileName
+			if err != nil {
+				permFrom = filepath.Join(gitDir, file.Template)
+			}
+			fi, err := os.Stat(permFrom)
+			if err != nil {
+				return false, fmt.Errorf("Could not get information about file %s: %w", permFrom, err)
+			}
+
+			err = os.MkdirAll(filepath.Dir(fileName), dirPerm)
+			if err != nil {
+				return false, fmt.Errorf("Could not create directory for %s: %w", file.Output, err)
+			}
+
+			err = writeFileAtomic(fileName, output.Bytes(), fi.Mode().Perm())
+			if err != nil {
+				return false, err
+			}
+
+			_, err = worktree.Add(file.Output)
+			if err != nil {
+				return false, err
+			}
+			staged = true
+		}
+
+		return staged, nil
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetRenderFiles(t *testing.T) {
+	t.Cleanup(func() { viper.Set("render_files", nil) })
+	useTestRepo(t, map[string]string{
+		"VERSION.tmpl": "{{.Version}}\n",
+		"BAD.tmpl":     "{{.Version\n",
+	})
+
+	tests := map[string]struct {
+		files       []map[string]string
+		expectError bool
+	}{
+		"no output":        {[]map[string]string{{"template": "VERSION.tmpl"}}, true},
+		"no template":      {[]map[string]string{{"output": "VERSION"}}, true},
+		"missing template": {[]map[string]string{{"template": "MISSING.tmpl", "output": "VERSION"}}, true},
+		"parse error":      {[]map[string]string{{"template": "BAD.tmpl", "output": "BAD"}}, true},
+		"valid":            {[]map[string]string{{"template": "VERSION.tmpl", "output": "VERSION"}}, false},
+	}
+
+	for name, test := range tests {
+		viper.Set("render_files", test.files)
+		files, err := getRenderFiles()
+		switch {
+		case test.expectError && err == nil:
+			t.Error(ExpectError, "for", name)
+		case !test.expectError && err != nil:
+			t.Error(ExpectNilError, err, "for", name)
+		case !test.expectError && (len(files) != 1 || files[0].tmpl == nil):
+			t.Errorf("incorrect result for %s: expected one parsed template, got %v", name, files)
+		}
+	}
+}
+
+func TestRenderStep(t *testing.T) {
+	t.Cleanup(func() { viper.Set("render_files", nil) })
+	worktree := useTestRepo(t, nil)
+	base := testCommit(t, worktree, "feat: start", map[string]string{
+		"VERSION.tmpl": "{{.Version}} {{.Previous}} {{.Base}}\n",
+		"SAME.tmpl":    "{{.Version}}\n",
+		"SAME":         "v1.1.0\n",
+		"BAD.tmpl":     "{{.Missing}}\n",
+	})
+	//revive:disable-next-line:add-constant
+	err := os.Chmod(filepath.Join(gitDir, "VERSION.tmpl"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("render_files", []map[string]string{{"template": "BAD.tmpl", "output": "BAD"}})
+	files, err := getRenderFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = renderStep(files, "v1.1.0", "v1.0.0")(worktree)
+	if err == nil {
+		t.Error(ExpectError, "for a template using a field that does not exist")
+	}
+
+	viper.Set("render_files", []map[string]string{{"template": "SAME.tmpl", "output": "SAME"}})
+	files, err = getRenderFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged, err := renderStep(files, "v1.1.0", "v1.0.0")(worktree)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if staged {
+		t.Error("incorrect result: expected an unchanged output not to be staged")
+	}
+
+	viper.Set("render_files", []map[string]string{{"template": "VERSION.tmpl", "output": "out/VERSION"}})
+	files, err = getRenderFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	staged, err = renderStep(files, "v1.1.0", "v1.0.0")(worktree)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if !staged {
+		t.Error("incorrect result: expected a new output to be staged")
+	}
+
+	fileName := filepath.Join(gitDir, "out", "VERSION")
+	output, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "v1.1.0 v1.0.0 " + base.String() + "\n"
+	if string(output) != expected {
+		t.Errorf("incorrect result: expected %q, got %q", expected, output)
+	}
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//revive:disable-next-line:add-constant
+	if fi.Mode().Perm() != 0o755 {
+		t.Error("incorrect result: expected a new output to take the permissions of its template, got", fi.Mode())
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/modfile"
+)
+
+var retractCmd = &cobra.Command{
+	Use:   "retract <version>",
+	Short: "Retract a broken release, and publish it with a patch release.",
+	Long: `Add a retract directive for a tagged version to go.mod,
+and release the next patch version so the Go tools see it.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: func(_ *cobra.Command, _ []string) error { return initConfig() },
+	RunE:    retract,
+}
+
+func init() {
+	retractCmd.Flags().Bool("dry-run", true, "Do a dry-run only")
+	retractCmd.Flags().String("reason", "", "Why the version is retracted, shown by the Go tools")
+
+	rootCmd.AddCommand(retractCmd)
+}
+
+// retract checks the version can be retracted, then makes a patch release with the retraction in it.
+func retract(cmd *cobra.Command, args []string) error {
+	pv := semver.ParseVersion(args[0])
+	if pv == nil {
+		return fmt.Errorf("%s is not a valid version", args[0])
+	}
+
+	tags, err := retrieveTags()
+	if err != nil {
+		return err
+	}
+	if _, ok := tags[normalizeVersion(pv.String())]; !ok {
+		return fmt.Errorf("There is no tag %s%s to retract", tagPrefix(), normalizeVersion(pv.String()))
+	}
+
+	modFile, err := readGoMod(moduleDir())
+	if err != nil {
+		return err
+	}
+	if modFile == nil {
+		return errors.New("Retracting a version needs a go.mod")
+	}
+
+	// Go versions always start with a v.
+	version := "v" + pv.String()
+	for _, r := range modFile.Retract {
+		if r.Low == version && r.High == version {
+			return fmt.Errorf("Version %s is already retracted", version)
+		}
+	}
+
+	// The release publishing a retraction is always a patch release.
+	reason, _ := cmd.Flags().GetString("reason")
+	return release(cmd, semver.Patch, retractStep(version, reason))
+}
+
+// retractStep adds a retract directive for a version to go.mod.
+func retractStep(version, reason string) releaseStep {
+	return func(worktree *git.Worktree) (bool, error) {
+		modFile, err := readGoMod(moduleDir())
+		if err != nil {
+			return false, err
+		}
+
+		err = modFile.AddRetract(modfile.VersionInterval{Low: version, High: version}, reason)
+		if err != nil {
+			return false, err
+		}
+		data, err := modFile.Format()
+		if err != nil {
+			return false, err
+		}
+
+		err = writeStaged(worktree, path.Join(moduleDir(), "go.mod"), data)
+		if err != nil {
+			return false, err
+		}
+
+		slog.Info("Retracted version " + version)
+		return true, nil
+	}
+}
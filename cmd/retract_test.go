@@ -0,0 +1,64 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+)
+
+func TestGetNextVersionForced(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	first := testCommit(t, worktree, "chore: init", nil)
+	testCommit(t, worktree, "feat: thing", map[string]string{"thing.go": "package m\n"})
+	tags := map[string]plumbing.Hash{"v1.0.0": first}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("minor", true, "")
+	cmd.Flags().String("set-version", "", "")
+
+	vsIncrement, pvNext, err := getNextVersion(cmd, semver.Patch, []string{""}, tags)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if vsIncrement != semver.Patch || pvNext.String() != "1.0.1" {
+		t.Errorf("incorrect result: expected a patch release of 1.0.1, got %s %s", vsIncrement, pvNext)
+	}
+
+	_ = cmd.Flags().Set("set-version", "2.0.0")
+	_, _, err = getNextVersion(cmd, semver.Patch, []string{""}, tags)
+	if err == nil {
+		t.Error(ExpectError, "for --set-version with a forced increment")
+	}
+
+	_ = cmd.Flags().Set("set-version", "")
+	testCommit(t, worktree, "feat: more\n\nRelease-As: 2.0.0\n", map[string]string{"more.go": "package m\n"})
+	_, _, err = getNextVersion(cmd, semver.Patch, []string{""}, tags)
+	if err == nil {
+		t.Error(ExpectError, "for a Release-As footer with a forced increment")
+	}
+}
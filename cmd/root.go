@@ -29,7 +29,6 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path"
 	"slices"
 	"sort"
@@ -37,7 +36,6 @@ import (
 	"github.com/csjewell/git-next-tag/semver"
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
@@ -74,30 +72,67 @@ func init() {
 	rootCmd.Flags().Bool("beta", false, "Increment beta version")
 	rootCmd.Flags().Bool("gamma", false, "Increment gamma version")
 	rootCmd.Flags().Bool("rc", false, "Increment release candidate version")
+	rootCmd.Flags().String("set-version", "", "Tag this exact version instead of incrementing")
+
+	rootCmd.Flags().Bool("all-modules", false, "Release every module that changed since its last tag")
+
+	rootCmd.PersistentFlags().String("module", "", "Release the module in this directory, with its own tags")
+	_ = viper.BindPFlag("module", rootCmd.PersistentFlags().Lookup("module"))
+
+	rootCmd.MarkFlagsMutuallyExclusive("all-modules", "module")
+	rootCmd.MarkFlagsMutuallyExclusive("all-modules", "set-version")
 }
 
 // initConfig reads in and creates or updates a config file.
 func initConfig() error {
-	// Find current directory.
-	gitDir, err := os.Getwd()
+	found, err := loadConfig()
 	if err != nil {
 		return err
 	}
 
+	if !found {
+		// Initialize the file.
+		err = askConfig()
+		if err != nil {
+			return errors.New("Configuration collection cancelled")
+		}
+
+		viper.Set("version_files", []string{})
+
+		file := path.Join(gitDir, ".git-next-tag")
+		err = viper.WriteConfigAs(file)
+		if err != nil {
+			return fmt.Errorf("Could not save configuration: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loadConfig opens the git repository and reads its config file, if there is one.
+//
+// It returns whether a config file was found.
+func loadConfig() (bool, error) {
+	// Find current directory.
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+
 	// Now get its top level git repository.
-	// repo is a global, we will need it in nextTag.
-	repo, err = git.PlainOpenWithOptions(gitDir, &git.PlainOpenOptions{
+	// repo and gitDir are globals, we will need them in nextTag.
+	repo, err = git.PlainOpenWithOptions(cwd, &git.PlainOpenOptions{
 		DetectDotGit:          true,
 		EnableDotGitCommonDir: true,
 	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// The return from Root() includes the .git directory, so shake it off.
 	storage, ok := repo.Storer.(*filesystem.Storage)
 	if !ok {
-		return errors.New("git not running on a filesystem?")
+		return false, errors.New("git not running on a filesystem?")
 	}
 	gitDir = path.Dir(storage.Filesystem().Root())
 
@@ -107,25 +142,16 @@ func initConfig() error {
 	viper.SetConfigName(".git-next-tag")
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		slog.Debug("Using config file:" + viper.ConfigFileUsed())
-	} else {
-		// Initialize the file.
-		err = askConfig()
-		if err != nil {
-			return errors.New("Configuration collection cancelled")
-		}
-
-		viper.Set("version_files", []string{})
-
-		file := path.Join(gitDir, ".git-next-tag")
-		err = viper.WriteConfigAs(file)
-		if err != nil {
-			return fmt.Errorf("Could not save configuration: %w", err)
-		}
+	err = viper.ReadInConfig()
+	if errors.As(err, &viper.ConfigFileNotFoundError{}) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Could not read configuration: %w", err)
 	}
 
-	return nil
+	slog.Debug("Using config file:" + viper.ConfigFileUsed())
+	return true, nil
 }
 
 func askConfig() error {
@@ -178,26 +204,158 @@ func askBoolean(sQuestion string, def bool) (bool, error) {
 
 // nextTag gets the next tag requested, saves it, etc.
 func nextTag(cmd *cobra.Command, _ []string) error {
+	return release(cmd, semver.NonSegment)
+}
+
+// release tags the next version, with any further changes the steps make going into the version commit.
+//
+// The increment is vsForced if it is not semver.NonSegment, instead of coming from the flags or the commits.
+func release(cmd *cobra.Command, vsForced semver.VersionSegment, extraSteps ...releaseStep) error {
 	err := isTreeClean()
 	if err != nil {
 		return err
 	}
 
-	tags, err := retrieveTags()
+	lockstep, err := isLockstep()
+	if err != nil {
+		return err
+	}
+
+	// alias_tags is only used once the version commit is made, so it is checked before anything changes.
+	_, err = aliasTagSetting()
 	if err != nil {
 		return err
 	}
 
-	vsIncrement, pvNext, err := getNextVersion(cmd, tags)
+	all, _ := cmd.Flags().GetBool("all-modules")
+	if all && !lockstep {
+		return releaseAllModules(cmd)
+	}
+
+	// With lockstep versioning, every module shares the version of the greatest tag of any of them.
+	var (
+		modules []*workspaceModule
+		tags    map[string]plumbing.Hash
+	)
+	if lockstep {
+		modules, err = workspaceModules()
+		if err != nil {
+			return err
+		}
+		tags, err = lockstepTags(modules)
+	} else {
+		modules = []*workspaceModule{{dir: moduleDir()}}
+		tags, err = retrieveTags()
+	}
+	if err != nil {
+		return err
+	}
+
+	prefixes := []string{tagPrefix()}
+	if lockstep {
+		prefixes = nil
+		for _, module := range modules {
+			prefixes = append(prefixes, moduleTagPrefix(module.dir))
+		}
+	}
+
+	allTags := tags
+	tags, line, err := releaseLineTags(tags)
+	if err != nil {
+		return err
+	}
+
+	vsIncrement, pvNext, err := getNextVersion(cmd, vsForced, prefixes, tags)
+	if err != nil {
+		return err
+	}
+
+	err = checkReleaseLine(line, pvNext)
+	if err != nil {
+		return err
+	}
+	if _, ok := allTags[normalizeVersion(pvNext.String())]; ok {
+		return fmt.Errorf("Tag %s%s already exists on another branch", tagPrefix(), normalizeVersion(pvNext.String()))
+	}
+
+	releaseBranch, err := releaseBranchName(pvNext, line)
 	if err != nil {
 		return err
 	}
 
 	vNext := normalizeVersion(pvNext.String())
 
+	fragments, err := collectFragments()
+	if err != nil {
+		return err
+	}
+
+	previous, since := latestVersionTag(tags)
+	commits, err := commitsSince(since)
+	if err != nil {
+		return err
+	}
+	commits, err = moduleCommits(commits)
+	if err != nil {
+		return err
+	}
+
+	if !lockstep && previous != "" {
+		warnUnderstatedIncrement(vsIncrement, previous, since)
+	}
+
+	notes, err := renderReleaseNotes(vNext, fragments, commits)
+	if err != nil {
+		return err
+	}
+
 	dryrun, _ := cmd.Flags().GetBool("dry-run")
-	filesToProcess := viper.GetStringSlice("version_files")
-	err = updateFiles(vNext, filesToProcess, dryrun)
+	if dryrun && notes != "" {
+		slog.Info("Release notes:\n" + notes)
+	}
+
+	filesToProcess, err := getVersionFiles()
+	if err != nil {
+		return err
+	}
+
+	modDirs := make([]string, 0, len(modules))
+	for _, module := range modules {
+		modDirs = append(modDirs, module.dir)
+	}
+
+	// A module released on its own has its requires of the other modules pointed at their latest tags,
+	// and with lockstep versioning, the requires between the modules are pointed at the new version.
+	var (
+		updating     map[string]bool
+		requireSteps []releaseStep
+	)
+	if lockstep {
+		updating, requireSteps, err = lockstepRequireSteps(modules, vNext)
+		if err != nil {
+			return err
+		}
+	} else {
+		siblings, err := latestSiblingTags(moduleDir())
+		if err != nil {
+			return err
+		}
+		extraSteps = append(extraSteps, siblingRequireStep(moduleDir(), siblings))
+	}
+
+	err = checkGoMods(modDirs, updating)
+	if err != nil {
+		return err
+	}
+
+	steps, err := releaseSteps(modDirs, pvNext, previous, notes, fragments, extraSteps...)
+	if err != nil {
+		return err
+	}
+	// The sums of the modules are worked out from what is staged, so nothing may change them after.
+	steps = append(steps, requireSteps...)
+
+	err = updateFiles(vNext, filesToProcess, dryrun, steps...)
 	if err != nil {
 		return err
 	}
@@ -207,11 +365,22 @@ func nextTag(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	err = doTagging(vNext, head.Hash(), dryrun)
+	if lockstep {
+		err = doLockstepTagging(modules, vNext, head.Hash(), notes, dryrun)
+	} else {
+		err = doTagging(vNext, head.Hash(), notes, dryrun)
+	}
 	if err != nil {
 		return err
 	}
 
+	if releaseBranch != "" {
+		err = createReleaseBranch(releaseBranch, head.Hash())
+		if err != nil {
+			return err
+		}
+	}
+
 	if viper.GetBool("always_leave_version_pre") {
 		err := afterTag(vsIncrement, pvNext, filesToProcess, dryrun)
 		if err != nil {
@@ -222,21 +391,37 @@ func nextTag(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// releaseSteps are the steps that make the changes going into the version commit of the modules in modDirs,
+// after the extra steps.
+func releaseSteps(
+	modDirs []string,
+	pvNext *semver.ParsedVersion,
+	previous, notes string,
+	fragments []changelogFragment,
+	extraSteps ...releaseStep,
+) ([]releaseStep, error) {
+	renderFiles, err := getRenderFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := slices.Clone(extraSteps)
+	steps = append(steps, changelogStep(notes, fragments))
+	for _, dir := range modDirs {
+		steps = append(steps, goMajorVersionStep(dir, pvNext))
+	}
+	steps = append(steps, renderStep(renderFiles, normalizeVersion(pvNext.String()), previous), copyrightStep())
+
+	return steps, nil
+}
+
 func afterTag(
 	vsIncrement semver.VersionSegment,
 	pvNext *semver.ParsedVersion,
-	filesToProcess []string,
+	filesToProcess []versionFile,
 	dryrun bool,
 ) error {
-	var vsNext semver.VersionSegment
-	switch vsIncrement {
-	case semver.Major, semver.Minor:
-		vsNext = semver.Patch
-	default:
-		vsNext = vsIncrement
-	}
-
-	pvFinal, err := pvNext.IncrementVersion(vsNext, true)
+	pvFinal, err := preVersionAfter(vsIncrement, pvNext)
 	if err != nil {
 		return err
 	}
@@ -250,20 +435,51 @@ func afterTag(
 	return nil
 }
 
+// preVersionAfter is the prerelease marker version afterTag leaves in the version files after a release:
+// the next patch version after a major or minor release, or the next one of the same kind otherwise.
+func preVersionAfter(vsIncrement semver.VersionSegment, pvNext *semver.ParsedVersion) (*semver.ParsedVersion, error) {
+	vsNext := vsIncrement
+	if vsIncrement == semver.Major || vsIncrement == semver.Minor {
+		vsNext = semver.Patch
+	}
+	return pvNext.IncrementVersion(vsNext, true)
+}
+
+// checkAlreadyTagged returns HEAD, unless it is already in the history of a version tag of the module.
 func checkAlreadyTagged() (*plumbing.Reference, error) {
 	head, err := repo.Reference(plumbing.HEAD, true)
 	if err != nil {
 		return nil, err
 	}
 
-	// We expect errors to happen on this run.
-	// TODO: Make it JUST "Error code 128" we ignore.
-	headHash := head.Hash().String()
-	out, _ := exec.Command("git", "describe", "--contains", headHash).Output()
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
 
-	possibleTag := string(out)
-	if possibleTag != "" {
-		return nil, fmt.Errorf("Repository is already tagged with %s and no more commits have been made", possibleTag)
+	// Only the version tags of the module being released count: not those of other modules, nor alias tags.
+	tags, err := retrieveTags()
+	if err != nil {
+		return nil, err
+	}
+	tagVersions, tagNames := sortTagVersions(tags)
+	for _, pv := range tagVersions {
+		hash := tags[tagNames[pv]]
+		contained := hash == head.Hash()
+		if !contained {
+			tagCommit, err := repo.CommitObject(hash)
+			if err != nil {
+				return nil, err
+			}
+			contained, err = headCommit.IsAncestor(tagCommit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if contained {
+			return nil, fmt.Errorf("Repository is already tagged with %s and no more commits have been made",
+				tagPrefix()+tagNames[pv])
+		}
 	}
 
 	return head, nil
@@ -271,16 +487,28 @@ func checkAlreadyTagged() (*plumbing.Reference, error) {
 
 // getNextVersion gets the next version based on previous one, if a previous one exists.
 // Otherwise, the "next version" is 0.1.0.
-func getNextVersion(cmd *cobra.Command, tags map[string]*object.Tag) (
-	semver.VersionSegment, *semver.ParsedVersion, error,
-) {
+//
+// A version given with --set-version is used as is, after it is validated against the tags of every prefix.
+// Otherwise, a Release-As footer in the commits since the previous version overrides the requested increment.
+func getNextVersion(
+	cmd *cobra.Command, vsForced semver.VersionSegment, prefixes []string, tags map[string]plumbing.Hash,
+) (semver.VersionSegment, *semver.ParsedVersion, error) {
 	var (
 		vsIncrement semver.VersionSegment
 		pvNext      *semver.ParsedVersion
-		err         error
 	)
 
-	if len(tags) == 0 {
+	tagVersions, tagNames := sortTagVersions(tags)
+
+	vSet, _ := cmd.Flags().GetString("set-version")
+	if vSet != "" {
+		if vsForced != semver.NonSegment {
+			return semver.NonSegment, nil, fmt.Errorf("--set-version cannot be used, as this is a %s release", vsForced)
+		}
+		return getSetVersion(vSet, prefixes, tagVersions, tags)
+	}
+
+	if len(tagVersions) == 0 {
 		vNext, err := askInitialTagging()
 		if err != nil {
 			return semver.NonSegment, nil, err
@@ -291,10 +519,79 @@ func getNextVersion(cmd *cobra.Command, tags map[string]*object.Tag) (
 		return vsIncrement, pvNext, err
 	}
 
+	pvCurrent := tagVersions[0]
+	vCurrent := pvCurrent.String()
+	slog.Debug("Current tag: " + normalizeVersion(vCurrent))
+
+	return incrementVersion(cmd, vsForced, pvCurrent, tags[tagNames[pvCurrent]], semver.NonSegment)
+}
+
+// incrementVersion works out the version after pvCurrent, which is tagged on since.
+//
+// A Release-As footer in the commits since it overrides the increment, which is vsForced if it is not
+// semver.NonSegment, or comes from the flags. Without an increment flag, vsDefault is used, unless it is
+// semver.NonSegment too.
+func incrementVersion(
+	cmd *cobra.Command, vsForced semver.VersionSegment, pvCurrent *semver.ParsedVersion, since plumbing.Hash,
+	vsDefault semver.VersionSegment,
+) (semver.VersionSegment, *semver.ParsedVersion, error) {
+	pvForced, err := releaseAsVersion(since, pvCurrent)
+	if err != nil {
+		return semver.NonSegment, nil, err
+	}
+
+	if vsForced != semver.NonSegment {
+		if pvForced != nil {
+			return semver.NonSegment, nil, fmt.Errorf("Version %s from a Release-As footer cannot be used, "+
+				"as this is a %s release", normalizeVersion(pvForced.String()), vsForced)
+		}
+
+		pvNext, err := pvCurrent.IncrementVersion(vsForced, false)
+		if err != nil {
+			return semver.NonSegment, nil, err
+		}
+		return vsForced, pvNext, nil
+	}
+
+	vsIncrement, err := getVersionSegment(cmd.Flags())
+	if pvForced != nil {
+		// No increment needs to be requested, but if one was, report what is overridden.
+		if err == nil {
+			if pvNext, err := pvCurrent.IncrementVersion(vsIncrement, false); err == nil {
+				slog.Info(fmt.Sprintf("Overriding the requested version %s with %s",
+					normalizeVersion(pvNext.String()), normalizeVersion(pvForced.String())))
+			}
+		}
+		return lowestSegment(pvForced), pvForced, nil
+	}
+	if err != nil && vsDefault == semver.NonSegment {
+		return semver.NonSegment, nil, err
+	}
+	if err != nil {
+		vsIncrement = vsDefault
+	}
+
+	pvNext, err := pvCurrent.IncrementVersion(vsIncrement, false)
+	if err != nil {
+		return semver.NonSegment, nil, err
+	}
+
+	return vsIncrement, pvNext, nil
+}
+
+// sortTagVersions parses the tags that are versions, and sorts them with the greatest version first.
+//
+// It also returns the name of the tag each version came from.
+func sortTagVersions(tags map[string]plumbing.Hash) ([]*semver.ParsedVersion, map[*semver.ParsedVersion]string) {
 	tagVersions := make([]*semver.ParsedVersion, 0, len(tags))
+	tagNames := make(map[*semver.ParsedVersion]string, len(tags))
 	for k := range tags {
 		pv := semver.ParseVersion(k)
+		if pv == nil {
+			continue
+		}
 		tagVersions = append(tagVersions, pv)
+		tagNames[pv] = k
 	}
 
 	sort.Sort(semver.ParsedVersionSlice(tagVersions))
@@ -302,21 +599,58 @@ func getNextVersion(cmd *cobra.Command, tags map[string]*object.Tag) (
 	// To turn the slice around so that the greatest versions are first
 	slices.Reverse(tagVersions)
 
-	pvCurrent := tagVersions[0]
-	vCurrent := pvCurrent.String()
-	slog.Debug("Current tag: " + normalizeVersion(vCurrent))
+	return tagVersions, tagNames
+}
+
+// latestVersionTag finds the tag with the greatest version, and the commit it is on.
+//
+// If there are no version tags, it returns "" and the zero hash.
+func latestVersionTag(tags map[string]plumbing.Hash) (string, plumbing.Hash) {
+	tagVersions, tagNames := sortTagVersions(tags)
+	if len(tagVersions) == 0 {
+		return "", plumbing.ZeroHash
+	}
 
-	vsIncrement, err = getVersionSegment(cmd.Flags())
+	tag := tagNames[tagVersions[0]]
+	return tag, tags[tag]
+}
+
+// getSetVersion checks the version given by --set-version can be tagged with each of the prefixes.
+//
+// tagVersions is expected to be sorted with the greatest version first.
+func getSetVersion(
+	vSet string, prefixes []string, tagVersions []*semver.ParsedVersion, tags map[string]plumbing.Hash,
+) (
+	semver.VersionSegment, *semver.ParsedVersion, error,
+) {
+	var pvCurrent *semver.ParsedVersion
+	if len(tagVersions) != 0 {
+		pvCurrent = tagVersions[0]
+	}
+
+	pvSet, err := parseNewVersion(vSet, pvCurrent)
 	if err != nil {
 		return semver.NonSegment, nil, err
 	}
 
-	pvNext, err = pvCurrent.IncrementVersion(vsIncrement, false)
+	vSet = normalizeVersion(pvSet.String())
+	if _, ok := tags[vSet]; ok {
+		return semver.NonSegment, nil, fmt.Errorf("Tag %s%s already exists", prefixes[0], vSet)
+	}
+
+	names := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		names = append(names, prefix+vSet)
+	}
+	tag, remote, err := remoteTagExists(names...)
 	if err != nil {
 		return semver.NonSegment, nil, err
 	}
+	if remote != "" {
+		return semver.NonSegment, nil, fmt.Errorf("Tag %s already exists on remote %s", tag, remote)
+	}
 
-	return vsIncrement, pvNext, err
+	return lowestSegment(pvSet), pvSet, nil
 }
 
 func normalizeVersion(s string) string {
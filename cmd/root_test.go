@@ -0,0 +1,148 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/viper"
+)
+
+func TestGetSetVersion(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	tags := map[string]plumbing.Hash{"v1.2.0": commit, "v1.3.0-rc.1": commit}
+
+	// The remote has a tag of the lib module that the local repository does not.
+	remoteDir := t.TempDir()
+	remoteRepo, err := git.PlainInit(remoteDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteWorktree, err := remoteRepo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteCommit, err := remoteWorktree.Commit("chore: init", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = remoteRepo.CreateTag("lib/v1.4.0", remoteCommit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { viper.Set("initial_v", nil) })
+	viper.Set("initial_v", true)
+
+	tagVersions, _ := sortTagVersions(tags)
+	tests := []struct {
+		name      string
+		version   string
+		prefixes  []string
+		expected  string
+		expectErr bool
+	}{
+		{"next version", "1.3.0", []string{""}, "1.3.0", false},
+		{"not greater", "1.2.0", []string{""}, "", true},
+		{"below the greatest", "1.2.5", []string{""}, "", true},
+		{"prerelease marker", "1.4.0-pre", []string{""}, "", true},
+		{"tagged locally", "1.3.0-rc.1", []string{""}, "", true},
+		{"tagged on the remote for another prefix", "1.4.0", []string{"", "lib/"}, "", true},
+		{"not tagged on the remote for this prefix", "1.4.0", []string{""}, "1.4.0", false},
+	}
+
+	for _, test := range tests {
+		_, pvSet, err := getSetVersion(test.version, test.prefixes, tagVersions, tags)
+		if test.expectErr {
+			if err == nil {
+				t.Error(test.name, ExpectError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.name, ExpectNilError, err)
+			continue
+		}
+		if pvSet.String() != test.expected {
+			t.Errorf("incorrect result for %s: expected %s, got %s", test.name, test.expected, pvSet)
+		}
+	}
+}
+
+func TestCheckAlreadyTagged(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	first := testCommit(t, worktree, "chore: init", nil)
+
+	// Tags of other modules and alias tags do not count.
+	for _, name := range []string{"semver/v1.0.0", "v1"} {
+		_, err := repo.CreateTag(name, first, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := checkAlreadyTagged()
+	if err != nil {
+		t.Error(ExpectNilError, err, "with only tags of other modules and alias tags on HEAD")
+	}
+
+	second := testCommit(t, worktree, "fix: second", map[string]string{"a.go": "package m\n"})
+	_, err = repo.CreateTag("v1.0.0", second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = checkAlreadyTagged()
+	if err == nil {
+		t.Error(ExpectError, "with a version tag on HEAD")
+	}
+
+	// A version tag on a later commit contains HEAD too.
+	testCheckout(t, "old", first)
+	_, err = checkAlreadyTagged()
+	if err == nil {
+		t.Error(ExpectError, "with a version tag on a later commit")
+	}
+
+	testCheckout(t, "main", second)
+	testCommit(t, worktree, "fix: third", map[string]string{"b.go": "package m\n"})
+	head, err := checkAlreadyTagged()
+	if err != nil {
+		t.Error(ExpectNilError, err, "with commits since the version tag")
+	}
+	if head == nil || head.Hash() == second {
+		t.Error("incorrect result: expected the new HEAD, got", head)
+	}
+}
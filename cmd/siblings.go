@@ -0,0 +1,461 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/zip"
+)
+
+// goSumPerm is the permission used when go.sum does not exist yet.
+const goSumPerm fs.FileMode = 0o644
+
+// taggedModule is a sibling module that was just tagged.
+type taggedModule struct {
+	dir     string
+	path    string
+	version string
+	// commit is the tagged commit, or the zero hash when it is the version commit being made.
+	commit plumbing.Hash
+}
+
+// siblingRequireStep points the requires of sibling modules in a module's go.mod at the versions just tagged,
+// and adds the sums of those versions to its go.sum.
+func siblingRequireStep(modDir string, tagged map[string]taggedModule) releaseStep {
+	return func(worktree *git.Worktree) (bool, error) {
+		modFile, err := readGoMod(modDir)
+		if err != nil || modFile == nil || len(tagged) == 0 {
+			return false, err
+		}
+
+		var sums []taggedModule
+		for _, req := range modFile.Require {
+			sibling, ok := tagged[req.Mod.Path]
+			if !ok || req.Mod.Version == sibling.version {
+				continue
+			}
+			// Requires are only moved forward. A pseudo-version does not parse, and is always moved.
+			pvRequired, pvSibling := semver.ParseVersion(req.Mod.Version), semver.ParseVersion(sibling.version)
+			if pvRequired != nil && !semver.ParsedVersionSlice([]*semver.ParsedVersion{pvRequired, pvSibling}).Less(0, 1) {
+				continue
+			}
+
+			// A sibling that moved to a new major version needs its imports changed too, which is left to people.
+			if sibling.path != req.Mod.Path {
+				slog.Warn(fmt.Sprintf("Module %s requires %s, which moved to %s, so it is left as it is",
+					modDir, req.Mod.Path, sibling.path))
+				continue
+			}
+
+			err := modFile.AddRequire(sibling.path, sibling.version)
+			if err != nil {
+				return false, err
+			}
+			sums = append(sums, sibling)
+			slog.Info(fmt.Sprintf("Module %s now requires %s %s", modDir, sibling.path, sibling.version))
+		}
+		if len(sums) == 0 {
+			return false, nil
+		}
+
+		modFile.Cleanup()
+		data, err := modFile.Format()
+		if err != nil {
+			return false, err
+		}
+		err = writeStaged(worktree, path.Join(modDir, "go.mod"), data)
+		if err != nil {
+			return false, err
+		}
+
+		err = updateGoSum(worktree, modDir, sums)
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+}
+
+// latestSiblingTags finds the latest tag of each module in the repository that a module requires,
+// so that releasing the module on its own points its requires at them.
+//
+// Prereleases, and tags that are not versions Go can use for the module path, are passed over.
+func latestSiblingTags(modDir string) (map[string]taggedModule, error) {
+	modFile, err := readGoMod(modDir)
+	if err != nil || modFile == nil {
+		return nil, err
+	}
+	required := make(map[string]bool, len(modFile.Require))
+	for _, req := range modFile.Require {
+		required[req.Mod.Path] = true
+	}
+
+	tracked, err := trackedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := make(map[string]taggedModule)
+	for _, file := range tracked {
+		dir := path.Dir(file)
+		if path.Base(file) != "go.mod" || dir == modDir {
+			continue
+		}
+
+		siblingFile, err := readGoMod(dir)
+		if err != nil {
+			return nil, err
+		}
+		if siblingFile == nil || siblingFile.Module == nil || !required[siblingFile.Module.Mod.Path] {
+			continue
+		}
+		modPath := siblingFile.Module.Mod.Path
+
+		tags, err := retrievePrefixedTags(moduleTagPrefix(dir))
+		if err != nil {
+			return nil, err
+		}
+		tagVersions, tagNames := sortTagVersions(tags)
+		for _, pv := range tagVersions {
+			version := tagNames[pv]
+			if newVersionData(pv).Prerelease != "" || module.Check(modPath, version) != nil {
+				continue
+			}
+			tagged[modPath] = taggedModule{dir: dir, path: modPath, version: version, commit: tags[version]}
+			break
+		}
+	}
+
+	return tagged, nil
+}
+
+// updateGoSum adds the sums of the tagged modules to the go.sum of a module,
+// replacing the sums of the other versions of them, as go mod tidy would.
+func updateGoSum(worktree *git.Worktree, modDir string, siblings []taggedModule) error {
+	file := path.Join(modDir, "go.sum")
+	fileName := filepath.Join(gitDir, file)
+	input, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("Could not read file %s: %w", file, err)
+	}
+
+	replaced := make(map[string]bool, len(siblings))
+	for _, sibling := range siblings {
+		replaced[sibling.path] = true
+	}
+
+	// Blank lines are kept where they are, and the file is only written if it changes.
+	var lines []string
+	if len(input) != 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(input), "\n"), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || !replaced[fields[0]] {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	// go.sum is sorted by module path, so each sum goes before the first line of a greater path.
+	for _, sibling := range siblings {
+		sums, err := moduleSums(sibling)
+		if err != nil {
+			return err
+		}
+		for _, sum := range sums {
+			at := slices.IndexFunc(lines, func(line string) bool {
+				fields := strings.Fields(line)
+				return len(fields) != 0 && fields[0] > sibling.path
+			})
+			if at < 0 {
+				at = len(lines)
+			}
+			lines = slices.Insert(lines, at, sum)
+		}
+	}
+
+	output := []byte(strings.Join(lines, "\n") + "\n")
+	if bytes.Equal(input, output) {
+		return nil
+	}
+
+	perm := goSumPerm
+	if fi, err := os.Stat(fileName); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	err = writeFileAtomic(fileName, output, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = worktree.Add(file)
+	return err
+}
+
+// moduleSums works out the go.sum lines of a tagged module the way the module proxy would,
+// from the module zip made from the tagged commit.
+//
+// A module with the zero hash as its commit is tagged in the version commit being made,
+// so its zip is made from what is staged.
+func moduleSums(sibling taggedModule) ([]string, error) {
+	subdir := ""
+	if sibling.dir != "." {
+		subdir = sibling.dir
+	}
+	mod := module.Version{Path: sibling.path, Version: sibling.version}
+
+	zipFile, err := os.CreateTemp("", "git-next-tag-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(zipFile.Name())
+
+	var files []zip.File
+	if sibling.commit.IsZero() {
+		files, err = stagedModuleFiles(sibling.dir)
+		if err == nil {
+			err = zip.Create(zipFile, mod, files)
+		}
+	} else {
+		err = zip.CreateFromVCS(zipFile, mod, gitDir, sibling.commit.String(), subdir)
+	}
+	if closeErr := zipFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not make the module zip of %s %s: %w", mod.Path, mod.Version, err)
+	}
+
+	zipSum, err := dirhash.HashZip(zipFile.Name(), dirhash.Hash1)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := taggedGoMod(sibling, files)
+	if err != nil {
+		return nil, err
+	}
+	goModSum, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader([]byte(contents))), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		fmt.Sprintf("%s %s %s", mod.Path, mod.Version, zipSum),
+		fmt.Sprintf("%s %s/go.mod %s", mod.Path, mod.Version, goModSum),
+	}, nil
+}
+
+// taggedGoMod reads the go.mod of a tagged module, from its commit or from the staged files of its zip.
+func taggedGoMod(sibling taggedModule, files []zip.File) (string, error) {
+	if sibling.commit.IsZero() {
+		for _, file := range files {
+			if file.Path() != "go.mod" {
+				continue
+			}
+			reader, err := file.Open()
+			if err != nil {
+				return "", err
+			}
+			defer reader.Close()
+			contents, err := io.ReadAll(reader)
+			return string(contents), err
+		}
+		return "", fmt.Errorf("No go.mod is staged in %s", sibling.dir)
+	}
+
+	commit, err := repo.CommitObject(sibling.commit)
+	if err != nil {
+		return "", err
+	}
+	goMod, err := commit.File(path.Join(sibling.dir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	return goMod.Contents()
+}
+
+// stagedFile is a file as it is staged, for making the zip of a module that is not committed yet.
+type stagedFile struct {
+	// path is relative to the directory of the module.
+	path  string
+	entry *index.Entry
+}
+
+func (file stagedFile) Path() string { return file.path }
+
+func (file stagedFile) Lstat() (fs.FileInfo, error) {
+	mode, err := file.entry.Mode.ToOSFileMode()
+	if err != nil {
+		return nil, err
+	}
+	blob, err := repo.BlobObject(file.entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return stagedFileInfo{name: path.Base(file.path), size: blob.Size, mode: mode}, nil
+}
+
+func (file stagedFile) Open() (io.ReadCloser, error) {
+	blob, err := repo.BlobObject(file.entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return blob.Reader()
+}
+
+// stagedFileInfo is the little a module zip needs to know about a staged file.
+type stagedFileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (fi stagedFileInfo) Name() string       { return fi.name }
+func (fi stagedFileInfo) Size() int64        { return fi.size }
+func (fi stagedFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi stagedFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi stagedFileInfo) IsDir() bool        { return false }
+func (fi stagedFileInfo) Sys() any           { return nil }
+
+// stagedModuleFiles lists the staged files in the directory of a module.
+// zip.Create leaves out the ones in nested modules.
+func stagedModuleFiles(modDir string) ([]zip.File, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []zip.File
+	for _, entry := range idx.Entries {
+		name := entry.Name
+		if modDir != "." {
+			var ok bool
+			name, ok = strings.CutPrefix(name, modDir+"/")
+			if !ok {
+				continue
+			}
+		}
+		files = append(files, stagedFile{path: name, entry: entry})
+	}
+	return files, nil
+}
+
+// repositoryModules finds the module paths of every go.mod tracked in the repository.
+func repositoryModules() (map[string]bool, error) {
+	tracked, err := trackedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, file := range tracked {
+		if path.Base(file) != "go.mod" {
+			continue
+		}
+
+		modFile, err := readGoMod(path.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		if modFile != nil && modFile.Module != nil {
+			paths[modFile.Module.Mod.Path] = true
+		}
+	}
+	return paths, nil
+}
+
+// goModProblems finds what in a module's go.mod would break it for its users once it is tagged:
+// replaces pointing at local directories, and pseudo-versions of other modules in the repository.
+//
+// Requires of the module paths in updating are left out, as they are about to be pointed at new tags.
+func goModProblems(modDir string, siblings, updating map[string]bool) ([]string, error) {
+	modFile, err := readGoMod(modDir)
+	if err != nil || modFile == nil {
+		return nil, err
+	}
+
+	file := path.Join(modDir, "go.mod")
+	var problems []string
+	for _, rep := range modFile.Replace {
+		if modfile.IsDirectoryPath(rep.New.Path) {
+			problems = append(problems, fmt.Sprintf("%s:%d: replace %s => %s points at a local directory",
+				file, rep.Syntax.Start.Line, rep.Old.Path, rep.New.Path))
+		}
+	}
+	for _, req := range modFile.Require {
+		if siblings[req.Mod.Path] && !updating[req.Mod.Path] && module.IsPseudoVersion(req.Mod.Version) {
+			problems = append(problems, fmt.Sprintf("%s:%d: require %s %s is a pseudo-version of a module "+
+				"in this repository, instead of a tagged version", file, req.Syntax.Start.Line, req.Mod.Path, req.Mod.Version))
+		}
+	}
+
+	return problems, nil
+}
+
+// checkGoMods refuses to release modules whose go.mod would break them for their users, reporting every problem.
+func checkGoMods(modDirs []string, updating map[string]bool) error {
+	siblings, err := repositoryModules()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, modDir := range modDirs {
+		found, err := goModProblems(modDir, siblings, updating)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, found...)
+	}
+
+	for _, problem := range problems {
+		slog.Error(problem)
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("Cannot release with %d problem(s) in go.mod", len(problems))
+	}
+	return nil
+}
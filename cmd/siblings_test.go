@@ -0,0 +1,185 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateGoSum(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod":     "module example.com/m\n",
+		"lib/go.mod": "module example.com/m/lib\n",
+		"lib/lib.go": "package lib\n",
+	})
+	commit := testCommit(t, worktree, "chore: init", nil)
+
+	goSum := "example.com/a v1.0.0 h1:a\nexample.com/a v1.0.0/go.mod h1:a\n\n" +
+		"example.com/m/lib v0.9.0 h1:old\nexample.com/m/lib v0.9.0/go.mod h1:old\n" +
+		"example.com/z v1.0.0/go.mod h1:z\n"
+	testCommit(t, worktree, "chore: sums", map[string]string{"go.sum": goSum})
+
+	lib := taggedModule{dir: "lib", path: "example.com/m/lib", version: "v1.0.0", commit: commit}
+	err := updateGoSum(worktree, ".", []taggedModule{lib})
+	if err != nil {
+		t.Fatal(ExpectNilError, err)
+	}
+
+	output, err := os.ReadFile(filepath.Join(gitDir, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(output), "\n")
+	//revive:disable-next-line:add-constant
+	if len(lines) != 7 || lines[2] != "" || lines[5] != "example.com/z v1.0.0/go.mod h1:z" {
+		t.Error("incorrect result: expected the blank line and order kept, got", string(output))
+	}
+	if !strings.HasPrefix(lines[3], "example.com/m/lib v1.0.0 h1:") ||
+		!strings.HasPrefix(lines[4], "example.com/m/lib v1.0.0/go.mod h1:") {
+		t.Error("incorrect result: expected the sums of v1.0.0 in place of v0.9.0, got", string(output))
+	}
+
+	err = updateGoSum(worktree, ".", []taggedModule{lib})
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	again, err := os.ReadFile(filepath.Join(gitDir, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(output) {
+		t.Error("incorrect result: expected no change the second time, got", string(again))
+	}
+}
+
+func TestLatestSiblingTags(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod": "module example.com/m\n\n" +
+			"require (\n\texample.com/m/lib v1.0.0\n\texample.com/m/v2lib/v2 v2.0.0\n)\n",
+		"lib/go.mod":    "module example.com/m/lib\n",
+		"v2lib/go.mod":  "module example.com/m/v2lib/v2\n",
+		"other/go.mod":  "module example.com/m/other\n",
+		"other/main.go": "package main\n",
+	})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	for _, name := range []string{"lib/v1.0.0", "lib/v1.1.0", "lib/v1.2.0-rc.1", "v2lib/v1.5.0", "other/v1.0.0"} {
+		_, err := repo.CreateTag(name, commit, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tagged, err := latestSiblingTags(".")
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	lib := tagged["example.com/m/lib"]
+	if len(tagged) != 1 || lib.version != "v1.1.0" || lib.dir != "lib" {
+		t.Error("incorrect result: expected only the latest release of lib, got", tagged)
+	}
+}
+
+func TestSiblingRequireStep(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod":     "module example.com/m\n\nrequire example.com/m/lib v1.2.0\n",
+		"lib/go.mod": "module example.com/m/lib\n",
+	})
+	commit := testCommit(t, worktree, "chore: init", nil)
+	lib := taggedModule{dir: "lib", path: "example.com/m/lib", version: "v1.1.0", commit: commit}
+
+	staged, err := siblingRequireStep(".", map[string]taggedModule{lib.path: lib})(worktree)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if staged {
+		t.Error("incorrect result: expected a newer require to be left as it is")
+	}
+
+	lib.version = "v1.3.0"
+	staged, err = siblingRequireStep(".", map[string]taggedModule{lib.path: lib})(worktree)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	output, err := os.ReadFile(filepath.Join(gitDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !staged || !strings.Contains(string(output), "require example.com/m/lib v1.3.0") {
+		t.Error("incorrect result: expected the require moved to v1.3.0, got", string(output))
+	}
+}
+
+func TestGoModProblems(t *testing.T) {
+	useTestRepo(t, map[string]string{
+		"go.mod": "module example.com/m\n\n" +
+			"require (\n" +
+			"\texample.com/m/lib v1.0.1-0.20240101000000-0123456789ab\n" +
+			"\texample.com/m/next v1.2.1-0.20240101000000-0123456789ab\n" +
+			"\texample.com/m/tagged v1.0.0\n" +
+			"\texample.com/other v0.0.0-20240101000000-0123456789ab\n" +
+			")\n\n" +
+			"replace example.com/m/tagged => ./tagged\n\n" +
+			"replace example.com/other => example.com/fork v1.0.0\n",
+	})
+	siblings := map[string]bool{"example.com/m/lib": true, "example.com/m/next": true, "example.com/m/tagged": true}
+
+	tests := map[string]struct {
+		updating map[string]bool
+		expected []string
+	}{
+		"no siblings updating": {nil, []string{
+			"go.mod:10: replace example.com/m/tagged => ./tagged points at a local directory",
+			"go.mod:4: require example.com/m/lib v1.0.1-0.20240101000000-0123456789ab is a pseudo-version",
+			"go.mod:5: require example.com/m/next v1.2.1-0.20240101000000-0123456789ab is a pseudo-version",
+		}},
+		"next updating": {map[string]bool{"example.com/m/next": true}, []string{
+			"go.mod:10: replace example.com/m/tagged => ./tagged points at a local directory",
+			"go.mod:4: require example.com/m/lib v1.0.1-0.20240101000000-0123456789ab is a pseudo-version",
+		}},
+	}
+
+	for name, test := range tests {
+		problems, err := goModProblems(".", siblings, test.updating)
+		if err != nil {
+			t.Error(ExpectNilError, err, "for", name)
+		}
+		if len(problems) != len(test.expected) {
+			t.Errorf("incorrect result for %s: expected %d problems, got %q", name, len(test.expected), problems)
+			continue
+		}
+		for i, problem := range problems {
+			if !strings.HasPrefix(problem, test.expected[i]) {
+				t.Errorf("incorrect result for %s: expected %q, got %q", name, test.expected[i], problem)
+			}
+		}
+	}
+
+	problems, err := goModProblems("missing", siblings, nil)
+	if err != nil || len(problems) != 0 {
+		t.Error("incorrect result: expected no problems for a directory without go.mod, got", problems, err)
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// The structured updaters find the value at a key path through a parser,
+// then replace only the bytes of that value, so formatting and comments are kept.
+
+// errKeyNotFound is returned when a key path does not lead to a value.
+var errKeyNotFound = errors.New("key not found")
+
+// splitKeyPath splits a key path like .version or package.version into its keys.
+func splitKeyPath(keyPath string) []string {
+	return strings.Split(strings.TrimPrefix(keyPath, "."), ".")
+}
+
+// replaceJSON replaces the string at the key path of a JSON document.
+func replaceJSON(input, keyPath, newVersion string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	err := seekJSON(dec, splitKeyPath(keyPath))
+	if err != nil {
+		return "", fmt.Errorf("Could not find %s: %w", keyPath, err)
+	}
+
+	offset := int(dec.InputOffset())
+	tok, err := dec.Token()
+	if err != nil {
+		return "", fmt.Errorf("Could not read %s: %w", keyPath, err)
+	}
+	if _, ok := tok.(string); !ok {
+		return "", fmt.Errorf("%s is not a string", keyPath)
+	}
+
+	// The value starts after the : or , that separates it from what is before it.
+	end := int(dec.InputOffset())
+	start := offset + strings.IndexByte(input[offset:end], '"')
+
+	quoted, err := json.Marshal(newVersion)
+	if err != nil {
+		return "", err
+	}
+
+	return input[:start] + string(quoted) + input[end:], nil
+}
+
+// seekJSON reads the decoder up to the value at the keys.
+func seekJSON(dec *json.Decoder, keys []string) error {
+	var skip json.RawMessage
+	for _, key := range keys {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			found := false
+			for !found && dec.More() {
+				name, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				found = name == key
+				if !found {
+					err = dec.Decode(&skip)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if !found {
+				return errKeyNotFound
+			}
+		case json.Delim('['):
+			index, err := strconv.Atoi(key)
+			if err != nil {
+				return errKeyNotFound
+			}
+			for ; index > 0 && dec.More(); index-- {
+				err = dec.Decode(&skip)
+				if err != nil {
+					return err
+				}
+			}
+			if !dec.More() {
+				return errKeyNotFound
+			}
+		default:
+			return errKeyNotFound
+		}
+	}
+
+	return nil
+}
+
+// replaceYAML replaces the scalar at the key path of a YAML document.
+func replaceYAML(input, keyPath, newVersion string) (string, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(input), &doc)
+	if err != nil {
+		return "", err
+	}
+
+	node := &doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+
+	for _, key := range splitKeyPath(keyPath) {
+		node = yamlChild(node, key)
+		if node == nil {
+			return "", fmt.Errorf("Could not find %s: %w", keyPath, errKeyNotFound)
+		}
+	}
+	if node.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("%s is not a scalar", keyPath)
+	}
+
+	// Line and Column count from 1, and Column counts characters rather than bytes.
+	lines := strings.SplitAfter(input, "\n")
+	start := len(strings.Join(lines[:node.Line-1], ""))
+	line := lines[node.Line-1]
+	for column := 1; column < node.Column && line != ""; column++ {
+		_, size := utf8.DecodeRuneInString(line)
+		start += size
+		line = line[size:]
+	}
+
+	var end int
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		end = start + 1 + closingQuote(input[start+1:], '"')
+		newVersion = `"` + newVersion + `"`
+	case yaml.SingleQuotedStyle:
+		end = start + 1 + closingQuote(input[start+1:], '\'')
+		newVersion = `'` + newVersion + `'`
+	case 0:
+		end = start + len(node.Value)
+		if !strings.HasPrefix(input[start:], node.Value) {
+			return "", fmt.Errorf("Could not find the value of %s in place", keyPath)
+		}
+	default:
+		return "", fmt.Errorf("%s is not a plain or quoted scalar", keyPath)
+	}
+
+	return input[:start] + newVersion + input[end:], nil
+}
+
+// yamlChild finds the value at a key of a mapping, or an index of a sequence.
+func yamlChild(node *yaml.Node, key string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(key)
+		if err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index]
+		}
+	case yaml.DocumentNode, yaml.ScalarNode, yaml.AliasNode:
+	}
+	return nil
+}
+
+// closingQuote finds the end of a quoted string, just after its closing quote.
+//
+// Double quoted strings escape a quote with a backslash, single quoted strings by doubling it.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && strings.HasPrefix(s[i:], "''"):
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// replaceTOML replaces the string at the key path of a TOML document.
+func replaceTOML(input, keyPath, newVersion string) (string, error) {
+	keys := splitKeyPath(keyPath)
+
+	var (
+		parser unstable.Parser
+		table  []string
+		value  *unstable.Node
+	)
+	parser.Reset([]byte(input))
+	for value == nil && parser.NextExpression() {
+		expr := parser.Expression()
+		switch expr.Kind {
+		case unstable.Table:
+			table = tomlKeys(expr.Key())
+		case unstable.ArrayTable:
+			// Keys in arrays of tables cannot be given as a key path.
+			table = []string{""}
+		case unstable.KeyValue:
+			value = tomlValue(expr, table, keys)
+		default:
+		}
+	}
+	if err := parser.Error(); err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", fmt.Errorf("Could not find %s: %w", keyPath, errKeyNotFound)
+	}
+	if value.Kind != unstable.String {
+		return "", fmt.Errorf("%s is not a string", keyPath)
+	}
+
+	start := int(value.Raw.Offset)
+	end := start + int(value.Raw.Length)
+
+	// Keep whichever of the four kinds of string it was.
+	delimiter := input[start : start+1]
+	if strings.HasPrefix(input[start:end], strings.Repeat(delimiter, 3)) {
+		delimiter = strings.Repeat(delimiter, 3)
+	}
+
+	return input[:start] + delimiter + newVersion + delimiter + input[end:], nil
+}
+
+// tomlKeys collects the parts of a dotted key.
+func tomlKeys(iter unstable.Iterator) []string {
+	var keys []string
+	for iter.Next() {
+		keys = append(keys, string(iter.Node().Data))
+	}
+	return keys
+}
+
+// tomlValue finds the value of a key-value expression if it is at the keys, looking into inline tables.
+func tomlValue(expr *unstable.Node, prefix, keys []string) *unstable.Node {
+	path := append(append([]string{}, prefix...), tomlKeys(expr.Key())...)
+	if len(path) > len(keys) || strings.Join(path, "\x00") != strings.Join(keys[:len(path)], "\x00") {
+		return nil
+	}
+
+	value := expr.Value()
+	if len(path) == len(keys) {
+		return value
+	}
+	if value.Kind != unstable.InlineTable {
+		return nil
+	}
+
+	children := value.Children()
+	for children.Next() {
+		if found := tomlValue(children.Node(), path, keys); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+)
+
+var suggestCmd = &cobra.Command{
+	Use:   "suggest",
+	Short: "Suggest the next increment from the changes to the Go API.",
+	Long: `Compare the exported API of the Go module at the latest tag with HEAD,
+and suggest a major, minor or patch version from the changes.
+
+Both are loaded from the repository itself, so nothing needs to be checked out.`,
+	Args:    cobra.NoArgs,
+	PreRunE: func(_ *cobra.Command, _ []string) error { _, err := loadConfig(); return err },
+	RunE:    suggest,
+}
+
+func init() {
+	rootCmd.AddCommand(suggestCmd)
+}
+
+// suggest prints the API changes since the latest tag, and the increment they call for.
+//
+// On a maintenance branch, the latest tag is the latest one on its version line.
+func suggest(cmd *cobra.Command, _ []string) error {
+	tags, err := retrieveTags()
+	if err != nil {
+		return err
+	}
+	tags, _, err = releaseLineTags(tags)
+	if err != nil {
+		return err
+	}
+
+	previous, since := latestVersionTag(tags)
+	if previous == "" {
+		return errors.New("There are no version tags to compare against")
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	report, err := apiChanges(since, head.Hash(), moduleDir())
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	err = report.Text(out)
+	if err != nil {
+		return err
+	}
+
+	vsIncrement := apiIncrement(report, semver.ParseVersion(previous))
+	fmt.Fprintf(out, "Suggested increment since %s%s: %s\n", tagPrefix(), previous, vsIncrement)
+	return nil
+}
+
+// warnUnderstatedIncrement warns when the requested increment is less than the API changes since the previous tag
+// call for.
+//
+// Not being able to compare the APIs is not a reason to stop a release, so it is only logged.
+func warnUnderstatedIncrement(vsIncrement semver.VersionSegment, previous string, since plumbing.Hash) {
+	if vsIncrement != semver.Minor && vsIncrement != semver.Patch {
+		return
+	}
+
+	modFile, err := readGoMod(moduleDir())
+	if err != nil || modFile == nil {
+		return
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return
+	}
+
+	report, err := apiChanges(since, head.Hash(), moduleDir())
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Could not compare the API with %s: %s", previous, err))
+		return
+	}
+
+	// Segments are ordered from major down, so a smaller one is a greater increment.
+	if vsSuggested := apiIncrement(report, semver.ParseVersion(previous)); vsSuggested < vsIncrement {
+		slog.Warn(fmt.Sprintf("The API changes since %s%s call for a %s version, but a %s version was requested",
+			tagPrefix(), previous, vsSuggested, vsIncrement))
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestWarnUnderstatedIncrement(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"m.go":   "package m\n\nfunc A() {}\n",
+	})
+	first := testCommit(t, worktree, "chore: init", nil)
+	testCommit(t, worktree, "feat!: no A", map[string]string{"m.go": "package m\n\nfunc B() {}\n"})
+
+	var logged bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	warnUnderstatedIncrement(semver.Major, "v1.0.0", first)
+	if logged.Len() != 0 {
+		t.Error("incorrect result: expected no warning for a major version, got", logged.String())
+	}
+
+	warnUnderstatedIncrement(semver.Minor, "v1.0.0", first)
+	if !strings.Contains(logged.String(), "call for a "+semver.Major.String()+" version") {
+		t.Error("incorrect result: expected a warning for a minor version, got", logged.String())
+	}
+
+	logged.Reset()
+	warnUnderstatedIncrement(semver.Minor, "v0.1.0", first)
+	if logged.Len() != 0 {
+		t.Error("incorrect result: expected no warning for a minor version before 1.0.0, got", logged.String())
+	}
+}
+
+func TestSuggestOnReleaseLine(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"m.go":   "package m\n\nfunc A() {}\n",
+	})
+	first := testCommit(t, worktree, "chore: init", nil)
+	second := testCommit(t, worktree, "feat!: no A", map[string]string{"m.go": "package m\n\nfunc B() {}\n"})
+	for name, hash := range map[string]plumbing.Hash{"v1.3.0": first, "v2.0.0": second} {
+		_, err := repo.CreateTag(name, hash, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() { viper.Set("branches", nil) })
+	viper.Set("branches", []map[string]string{{"name": "release/*", "line": "minor"}})
+	testCheckout(t, "release/1.3", first)
+	testCommit(t, worktree, "feat: C", map[string]string{"m.go": "package m\n\nfunc A() {}\n\nfunc C() {}\n"})
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	err := suggest(cmd, nil)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	expected := "Suggested increment since v1.3.0: " + semver.Minor.String()
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("incorrect result: expected %q, got %q", expected, out.String())
+	}
+}
@@ -28,6 +28,8 @@ import (
 	"fmt"
 	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 
 	"github.com/csjewell/git-next-tag/semver"
 	"github.com/spf13/pflag"
@@ -109,3 +111,61 @@ func getVersionSegment(flags *pflag.FlagSet) (semver.VersionSegment, error) {
 
 	return semver.NonSegment, errors.New("Did not specify how to upgrade the version")
 }
+
+// parseNewVersion parses a version that was asked for explicitly,
+// and makes sure it is a version that can be tagged after the current one.
+func parseNewVersion(v string, pvCurrent *semver.ParsedVersion) (*semver.ParsedVersion, error) {
+	pvNew := semver.ParseVersion(v)
+	if pvNew == nil {
+		return nil, fmt.Errorf("%s is not a valid version", v)
+	}
+	if strings.HasSuffix(pvNew.String(), "-pre") {
+		return nil, fmt.Errorf("%s is a prerelease marker version, which cannot be tagged", v)
+	}
+	if pvCurrent != nil && !semver.ParsedVersionSlice([]*semver.ParsedVersion{pvCurrent, pvNew}).Less(0, 1) {
+		return nil, fmt.Errorf("%s is not greater than the current version %s", v, normalizeVersion(pvCurrent.String()))
+	}
+
+	return pvNew, nil
+}
+
+// lowestSegment returns the lowest segment a version has,
+// which is the one to increment when making a prerelease marker version after it.
+func lowestSegment(pv *semver.ParsedVersion) semver.VersionSegment {
+	matches := semver.RegexpString.FindStringSubmatch(pv.String())
+	switch matches[4] {
+	case "alpha":
+		return semver.Alpha
+	case "beta":
+		return semver.Beta
+	case "gamma":
+		return semver.Gamma
+	case "rc":
+		return semver.RelCand
+	}
+	return semver.Patch
+}
+
+// versionData is what version templates are rendered with.
+type versionData struct {
+	Major int
+	Minor int
+	Patch int
+	// Prerelease is everything after the patch level, with its leading dash, like -rc.1-pre.
+	Prerelease string
+	// Version is the whole version, without an initial v.
+	Version string
+}
+
+// newVersionData splits a version up for templates.
+func newVersionData(pv *semver.ParsedVersion) versionData {
+	version := pv.String()
+	matches := semver.RegexpString.FindStringSubmatch(version)
+	data := versionData{Version: version}
+	data.Major, _ = strconv.Atoi(matches[1])
+	data.Minor, _ = strconv.Atoi(matches[2])
+	data.Patch, _ = strconv.Atoi(matches[3])
+	data.Prerelease = strings.TrimPrefix(version, fmt.Sprintf("%d.%d.%d", data.Major, data.Minor, data.Patch))
+
+	return data
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+)
+
+func TestParseNewVersion(t *testing.T) {
+	tests := []struct {
+		version   string
+		current   string
+		expected  string
+		expectErr bool
+	}{
+		{"1.3.0", "", "1.3.0", false},
+		{"v1.3.0", "1.2.9", "1.3.0", false},
+		{"1.3.0-rc.1", "1.2.9", "1.3.0-rc.1", false},
+		{"1.3.0", "1.3.0-rc.1", "1.3.0", false},
+		{"1.3.0", "1.3.0", "", true},
+		{"1.2.0", "1.3.0", "", true},
+		{"1.3.0-pre", "1.2.0", "", true},
+		{"1.3", "1.2.0", "", true},
+	}
+
+	for _, test := range tests {
+		var pvCurrent *semver.ParsedVersion
+		if test.current != "" {
+			pvCurrent = semver.ParseVersion(test.current)
+		}
+
+		pvNew, err := parseNewVersion(test.version, pvCurrent)
+		if test.expectErr {
+			if err == nil {
+				t.Error(ExpectError, "for", test.version, "after", test.current)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(ExpectNilError, err)
+			continue
+		}
+		if pvNew.String() != test.expected {
+			t.Errorf("incorrect result for %s: expected %s, got %s", test.version, test.expected, pvNew)
+		}
+	}
+}
@@ -0,0 +1,571 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// workspaceModule is one of the modules released together with --all-modules.
+type workspaceModule struct {
+	// dir is the directory of the module, relative to the top of the repository.
+	dir string
+	// path is the module path in its go.mod, or "" if it has none.
+	path string
+	// requires are the module paths its go.mod requires.
+	requires []string
+}
+
+// moduleRelease is the release planned for a module.
+type moduleRelease struct {
+	*workspaceModule
+
+	previous    string
+	vsIncrement semver.VersionSegment
+	pvNext      *semver.ParsedVersion
+	commits     []*object.Commit
+	// branch is the maintenance branch to create at the tagged commit, or "" if none is.
+	branch string
+	// updatable is whether the requires of the module in its siblings can be pointed at the new tag.
+	updatable bool
+}
+
+// workspaceModules finds the modules in the modules setting, or in go.work if that is not set.
+func workspaceModules() ([]*workspaceModule, error) {
+	dirs := viper.GetStringSlice("modules")
+	if len(dirs) == 0 {
+		data, err := os.ReadFile(filepath.Join(gitDir, "go.work"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.New("There is no modules setting or go.work file to find the modules in")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not read file go.work: %w", err)
+		}
+
+		work, err := modfile.ParseWork("go.work", data, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, use := range work.Use {
+			dirs = append(dirs, use.Path)
+		}
+	}
+
+	modules := make([]*workspaceModule, 0, len(dirs))
+	for _, dir := range dirs {
+		dir = path.Clean(filepath.ToSlash(dir))
+		if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("Module directory %s is outside the repository", dir)
+		}
+
+		modFile, err := readGoMod(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		module := &workspaceModule{dir: dir}
+		if modFile != nil && modFile.Module != nil {
+			module.path = modFile.Module.Mod.Path
+			for _, req := range modFile.Require {
+				module.requires = append(module.requires, req.Mod.Path)
+			}
+		}
+		modules = append(modules, module)
+	}
+
+	return modules, nil
+}
+
+// dependencyOrder sorts the modules so that every module comes after the modules it requires.
+func dependencyOrder(modules []*workspaceModule) ([]*workspaceModule, error) {
+	modules = slices.Clone(modules)
+	sort.Slice(modules, func(i, j int) bool { return modules[i].dir < modules[j].dir })
+
+	byPath := make(map[string]*workspaceModule, len(modules))
+	for _, module := range modules {
+		if module.path != "" {
+			byPath[module.path] = module
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*workspaceModule]int, len(modules))
+	ordered := make([]*workspaceModule, 0, len(modules))
+
+	var visit func(module *workspaceModule) error
+	visit = func(module *workspaceModule) error {
+		switch state[module] {
+		case visiting:
+			return fmt.Errorf("Module %s is part of a require cycle", module.dir)
+		case visited:
+			return nil
+		}
+
+		state[module] = visiting
+		for _, req := range module.requires {
+			if dep, ok := byPath[req]; ok && dep != module {
+				err := visit(dep)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state[module] = visited
+		ordered = append(ordered, module)
+		return nil
+	}
+
+	for _, module := range modules {
+		err := visit(module)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// owningModule finds the directory of the module a file belongs to, which is the deepest one it is in.
+//
+// It returns "" if the file is in none of them.
+func owningModule(file string, dirs []string) string {
+	owner := ""
+	for _, dir := range dirs {
+		if inDir(file, dir) && (owner == "" || owner == "." || len(dir) > len(owner)) {
+			owner = dir
+		}
+	}
+	return owner
+}
+
+// commitIncrement works out the increment a module's commits call for from their Conventional Commits types.
+//
+// Breaking changes call for a major version, or a minor one before 1.0.0; features call for a minor version.
+func commitIncrement(commits []*object.Commit, pvCurrent *semver.ParsedVersion) semver.VersionSegment {
+	types := commitTypes()
+	vsIncrement := semver.Patch
+	for _, commit := range commits {
+		cc, lintErrors := parseConventionalCommit(cleanMessage(commit.Message), types)
+		if len(lintErrors) != 0 {
+			continue
+		}
+
+		switch {
+		case cc.breaking && newVersionData(pvCurrent).Major != 0:
+			return semver.Major
+		case cc.breaking, cc.kind == "feat":
+			vsIncrement = semver.Minor
+		}
+	}
+	return vsIncrement
+}
+
+// planModuleRelease works out the next version of a module, if it has changed since its last tag,
+// or requires a sibling module that is being released.
+//
+// On a maintenance branch, only the module's tags on the branch's version line count, as for a single release.
+//
+// It returns nil if the module has not changed.
+func planModuleRelease(
+	cmd *cobra.Command, module *workspaceModule, releasing map[string]bool,
+) (*moduleRelease, error) {
+	defer useModule(module.dir)()
+
+	allTags, err := retrieveTags()
+	if err != nil {
+		return nil, err
+	}
+	tags, rl, err := releaseLineTags(allTags)
+	if err != nil {
+		return nil, fmt.Errorf("Module %s: %w", module.dir, err)
+	}
+
+	previous, since := latestVersionTag(tags)
+	commits, err := commitsSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	// Version commits and merges change nothing of their own.
+	commits = slices.DeleteFunc(commits, func(commit *object.Commit) bool {
+		return commit.NumParents() > 1 || strings.HasPrefix(commit.Message, versionCommitPrefix)
+	})
+	changes, err := moduleCommits(commits)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 && !slices.ContainsFunc(module.requires, func(req string) bool { return releasing[req] }) {
+		return nil, nil //nolint:nilnil // Having nothing to release is not an error.
+	}
+
+	release := &moduleRelease{workspaceModule: module, previous: previous, commits: changes}
+	if previous == "" {
+		release.vsIncrement = semver.Patch
+		release.pvNext = semver.ParseVersion("0.1.0")
+	} else {
+		// The version is picked like a release of the module on its own would, with the commits deciding
+		// the increment when no flag does.
+		pvCurrent := semver.ParseVersion(previous)
+		release.vsIncrement, release.pvNext, err = incrementVersion(cmd, semver.NonSegment, pvCurrent, since,
+			commitIncrement(changes, pvCurrent))
+		if err != nil {
+			return nil, fmt.Errorf("Module %s: %w", module.dir, err)
+		}
+	}
+
+	err = checkReleaseLine(rl, release.pvNext)
+	if err != nil {
+		return nil, fmt.Errorf("Module %s: %w", module.dir, err)
+	}
+	vNext := normalizeVersion(release.pvNext.String())
+	if _, ok := allTags[vNext]; ok {
+		return nil, fmt.Errorf("Tag %s%s already exists on another branch", tagPrefix(), vNext)
+	}
+
+	if previous != "" {
+		warnUnderstatedIncrement(release.vsIncrement, previous, since)
+	}
+
+	release.branch, err = releaseBranchName(release.pvNext, rl)
+	if err != nil {
+		return nil, fmt.Errorf("Module %s: %w", module.dir, err)
+	}
+
+	return release, nil
+}
+
+// requirableTag works out whether the modules requiring a module can be pointed at its new tag,
+// which needs the tag to be a version Go can use for the module path they require.
+//
+// A module moving to a new major version is not, as the imports of the modules requiring it need changing too.
+func requirableTag(release *moduleRelease) bool {
+	if release.path == "" {
+		return false
+	}
+
+	vNext := normalizeVersion(release.pvNext.String())
+	err := module.Check(release.path, vNext)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("The modules requiring %s are left as they are, as tag %s%s cannot be used: %v",
+			release.path, moduleTagPrefix(release.dir), vNext, err))
+		return false
+	}
+	return true
+}
+
+// lockstepRequireSteps works out the steps that point the requires between the modules of a lockstep release
+// at the new version, along with the module paths whose requires they update.
+//
+// The version is refused if it is not one Go can use for a module that another requires,
+// as when a major version moves the module paths, since the imports would need changing too.
+func lockstepRequireSteps(modules []*workspaceModule, vNext string) (map[string]bool, []releaseStep, error) {
+	byPath := make(map[string]*workspaceModule, len(modules))
+	for _, dependency := range modules {
+		if dependency.path != "" {
+			byPath[dependency.path] = dependency
+		}
+	}
+
+	// The sums of each module are worked out from what is staged for the version commit,
+	// so the steps need to come after every other step.
+	tagged := make(map[string]taggedModule)
+	updating := make(map[string]bool)
+	for _, dependent := range modules {
+		for _, req := range dependent.requires {
+			dependency, ok := byPath[req]
+			if !ok || dependency == dependent {
+				continue
+			}
+
+			err := module.Check(dependency.path, vNext)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Module %s requires %s, which cannot be tagged %s%s: %w",
+					dependent.dir, dependency.path, moduleTagPrefix(dependency.dir), vNext, err)
+			}
+			tagged[dependency.path] = taggedModule{dir: dependency.dir, path: dependency.path, version: vNext}
+			updating[dependency.path] = true
+		}
+	}
+	if len(tagged) == 0 {
+		return nil, nil, nil
+	}
+
+	// A module's files are final once its own requires are updated, so the modules it requires go first.
+	ordered, err := dependencyOrder(modules)
+	if err != nil {
+		return nil, nil, err
+	}
+	steps := make([]releaseStep, 0, len(ordered))
+	for _, dependent := range ordered {
+		steps = append(steps, siblingRequireStep(dependent.dir, tagged))
+	}
+
+	return updating, steps, nil
+}
+
+// run makes the changes a release of the module makes, along with pointing its requires at the sibling modules
+// tagged before it, commits them and tags the new version.
+func (release *moduleRelease) run(dirs []string, tagged map[string]taggedModule) error {
+	defer useModule(release.dir)()
+	vNext := normalizeVersion(release.pvNext.String())
+
+	fragments, err := collectFragments()
+	if err != nil {
+		return err
+	}
+
+	notes, err := renderReleaseNotes(vNext, fragments, release.commits)
+	if err != nil {
+		return err
+	}
+
+	files, err := getVersionFiles()
+	if err != nil {
+		return err
+	}
+	// Modules nested in this one keep their own version files.
+	files = slices.DeleteFunc(files, func(file versionFile) bool {
+		return owningModule(file.Path, dirs) != release.dir
+	})
+
+	steps, err := releaseSteps([]string{release.dir}, release.pvNext, release.previous, notes, fragments,
+		siblingRequireStep(release.dir, tagged))
+	if err != nil {
+		return err
+	}
+
+	err = updateFiles(vNext, files, false, steps...)
+	if err != nil {
+		return err
+	}
+
+	head, err := checkAlreadyTagged()
+	if err != nil {
+		return err
+	}
+
+	err = createTag(tagPrefix()+vNext, vNext, head.Hash(), notes)
+	if err != nil {
+		return err
+	}
+
+	if release.branch != "" {
+		err = createReleaseBranch(release.branch, head.Hash())
+		if err != nil {
+			return err
+		}
+	}
+
+	if release.updatable {
+		tagged[release.path] = taggedModule{
+			dir:     release.dir,
+			path:    release.path,
+			version: vNext,
+			commit:  head.Hash(),
+		}
+	}
+
+	if viper.GetBool("always_leave_version_pre") {
+		return afterTag(release.vsIncrement, release.pvNext, files, false)
+	}
+	return nil
+}
+
+// releaseAllModules releases every module that has changed since its last tag, in dependency order,
+// after showing the plan and asking once.
+func releaseAllModules(cmd *cobra.Command) error {
+	if viper.IsSet("tag_prefix") {
+		return errors.New("tag_prefix cannot be used with --all-modules, as each module's tags start with its directory")
+	}
+
+	modules, err := workspaceModules()
+	if err != nil {
+		return err
+	}
+	modules, err = dependencyOrder(modules)
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, 0, len(modules))
+	for _, module := range modules {
+		dirs = append(dirs, module.dir)
+	}
+
+	var (
+		plan      []*moduleRelease
+		lines     []string
+		releasing = make(map[string]bool)
+	)
+	for _, module := range modules {
+		release, err := planModuleRelease(cmd, module, releasing)
+		if err != nil {
+			return err
+		}
+		if release == nil {
+			slog.Debug(fmt.Sprintf("Module %s has not changed since its last tag", module.dir))
+			continue
+		}
+
+		plan = append(plan, release)
+		release.updatable = requirableTag(release)
+		if release.updatable {
+			releasing[module.path] = true
+		}
+		prefix := moduleTagPrefix(module.dir)
+		previous := "(none)"
+		if release.previous != "" {
+			previous = prefix + release.previous
+		}
+		line := fmt.Sprintf("  %s: %s -> %s%s", module.dir, previous, prefix, normalizeVersion(release.pvNext.String()))
+		if release.branch != "" {
+			line += fmt.Sprintf(", creating branch %s", release.branch)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(plan) == 0 {
+		slog.Info("No module has changed since its last tag")
+		return nil
+	}
+
+	slog.Info("Release plan:\n" + strings.Join(lines, "\n"))
+
+	// Requires of the modules being released are pointed at their new tags on the way.
+	planned := make([]string, 0, len(plan))
+	for _, release := range plan {
+		planned = append(planned, release.dir)
+	}
+	err = checkGoMods(planned, releasing)
+	if err != nil {
+		return err
+	}
+
+	dryrun, _ := cmd.Flags().GetBool("dry-run")
+	err = confirmTagging(fmt.Sprintf("Releasing %d module(s). Continue?", len(plan)), dryrun)
+	if err != nil {
+		return err
+	}
+
+	tagged := make(map[string]taggedModule, len(plan))
+	for _, release := range plan {
+		err := release.run(dirs, tagged)
+		if err != nil {
+			return fmt.Errorf("Could not release module %s: %w", release.dir, err)
+		}
+	}
+
+	return nil
+}
+
+// isLockstep checks whether every module shares one version, from the versioning setting.
+func isLockstep() (bool, error) {
+	switch versioning := viper.GetString("versioning"); versioning {
+	case "", "independent":
+		return false, nil
+	case "lockstep":
+		if viper.IsSet("tag_prefix") || moduleDir() != "." {
+			return false, errors.New("tag_prefix and --module cannot be used with lockstep versioning, " +
+				"as every module is tagged together")
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("versioning is %s, but it can only be independent or lockstep", versioning)
+	}
+}
+
+// lockstepTags retrieves the version tags of every module, named without their prefixes.
+//
+// Where modules were tagged with the same version on different commits, the root module's tag is kept,
+// or else the tag of the module listed first.
+func lockstepTags(modules []*workspaceModule) (map[string]plumbing.Hash, error) {
+	modules = slices.Clone(modules)
+	sort.SliceStable(modules, func(i, j int) bool { return modules[i].dir == "." && modules[j].dir != "." })
+
+	tags := make(map[string]plumbing.Hash)
+	for _, module := range modules {
+		moduleTags, err := retrievePrefixedTags(moduleTagPrefix(module.dir))
+		if err != nil {
+			return nil, err
+		}
+		for name, hash := range moduleTags {
+			if semver.ParseVersion(name) == nil {
+				continue
+			}
+			if kept, ok := tags[name]; ok {
+				if kept != hash {
+					slog.Warn(fmt.Sprintf("Tag %s%s is on %s, so the tag of the same version on %s is used instead",
+						moduleTagPrefix(module.dir), name, shortHash(hash), shortHash(kept)))
+				}
+				continue
+			}
+			tags[name] = hash
+		}
+	}
+	return tags, nil
+}
+
+// doLockstepTagging tags every module with the same version, after asking once.
+func doLockstepTagging(
+	modules []*workspaceModule, version string, head plumbing.Hash, message string, dryrun bool,
+) error {
+	names := make([]string, 0, len(modules))
+	for _, module := range modules {
+		names = append(names, moduleTagPrefix(module.dir)+version)
+	}
+
+	err := confirmTagging(fmt.Sprintf("Creating tags %s for version %s. Continue?",
+		strings.Join(names, ", "), version), dryrun)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		err := createTag(name, version, head, message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright © 2023, 2024 Curtis Jewell <golang@curtisjewell.name>
+SPDX-License-Identifier: MIT
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/csjewell/git-next-tag/semver"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestLockstepTags(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	first := testCommit(t, worktree, "chore: init", nil)
+	second := testCommit(t, worktree, "fix: thing", map[string]string{"thing.go": "package m\n"})
+
+	for name, hash := range map[string]plumbing.Hash{
+		"lib/v1.0.0": first,
+		"v1.1.0":     second,
+		"lib/v1.1.0": first,
+		"lib/v1.2.0": second,
+		"other":      second,
+	} {
+		_, err := repo.CreateTag(name, hash, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tags, err := lockstepTags([]*workspaceModule{{dir: "lib"}, {dir: "."}})
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	//revive:disable-next-line:add-constant
+	if len(tags) != 3 {
+		t.Error("incorrect result: expected the three versions, got", tags)
+	}
+	if tags["v1.0.0"] != first || tags["v1.2.0"] != second {
+		t.Error("incorrect result: expected the versions of lib, got", tags)
+	}
+	if tags["v1.1.0"] != second {
+		t.Error("incorrect result: expected the root module's v1.1.0, got", tags["v1.1.0"])
+	}
+}
+
+func TestPlanModuleRelease(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"lib/go.mod": "module example.com/lib\n"})
+	first := testCommit(t, worktree, "chore: init", nil)
+	_, err := repo.CreateTag("lib/v1.0.0", first, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := &workspaceModule{dir: "lib", path: "example.com/lib"}
+	cmd := &cobra.Command{}
+
+	release, err := planModuleRelease(cmd, module, nil)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if release != nil {
+		t.Error("incorrect result: expected no release without changes, got", release.pvNext)
+	}
+
+	testCommit(t, worktree, "fix: lib", map[string]string{"lib/lib.go": "package lib\n"})
+	release, err = planModuleRelease(cmd, module, nil)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if release == nil || release.pvNext.String() != "1.0.1" {
+		t.Error("incorrect result: expected 1.0.1 for a fix, got", release)
+	}
+
+	testCommit(t, worktree, "fix: more\n\nRelease-As: 1.5.0\n", map[string]string{"lib/more.go": "package lib\n"})
+	release, err = planModuleRelease(cmd, module, nil)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if release == nil || release.pvNext.String() != "1.5.0" {
+		t.Error("incorrect result: expected 1.5.0 from the Release-As footer, got", release)
+	}
+
+}
+
+func TestDependencyOrder(t *testing.T) {
+	app := &workspaceModule{dir: ".", path: "example.com/m", requires: []string{"example.com/m/lib", "example.com/x"}}
+	lib := &workspaceModule{dir: "lib", path: "example.com/m/lib", requires: []string{"example.com/m/base"}}
+	base := &workspaceModule{dir: "base", path: "example.com/m/base"}
+
+	ordered, err := dependencyOrder([]*workspaceModule{app, lib, base})
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	dirs := make([]string, 0, len(ordered))
+	for _, module := range ordered {
+		dirs = append(dirs, module.dir)
+	}
+	if strings.Join(dirs, " ") != "base lib ." {
+		t.Error("incorrect result: expected every module after the modules it requires, got", dirs)
+	}
+
+	base.requires = []string{"example.com/m"}
+	_, err = dependencyOrder([]*workspaceModule{app, lib, base})
+	if err == nil {
+		t.Error(ExpectError, "for a require cycle")
+	}
+}
+
+func TestCommitIncrement(t *testing.T) {
+	commits := func(messages ...string) []*object.Commit {
+		result := make([]*object.Commit, 0, len(messages))
+		for _, message := range messages {
+			result = append(result, &object.Commit{Message: message})
+		}
+		return result
+	}
+
+	tests := []struct {
+		commits  []*object.Commit
+		current  string
+		expected semver.VersionSegment
+	}{
+		{commits("fix: a", "docs: b"), "1.2.3", semver.Patch},
+		{commits("fix: a", "not conventional"), "1.2.3", semver.Patch},
+		{commits("fix: a", "feat: b"), "1.2.3", semver.Minor},
+		{commits("feat!: a", "fix: b"), "1.2.3", semver.Major},
+		{commits("fix: a\n\nBREAKING CHANGE: b"), "1.2.3", semver.Major},
+		{commits("feat!: a"), "0.4.1", semver.Minor},
+		{commits("fix: a\n\nBREAKING CHANGE: b"), "0.4.1", semver.Minor},
+	}
+
+	for _, test := range tests {
+		got := commitIncrement(test.commits, semver.ParseVersion(test.current))
+		if got != test.expected {
+			t.Errorf("incorrect result for %s at %s: expected %s, got %s",
+				test.commits[0].Message, test.current, test.expected, got)
+		}
+	}
+}
+
+func TestLockstepRequireSteps(t *testing.T) {
+	worktree := useTestRepo(t, nil)
+	testCommit(t, worktree, "chore: init", map[string]string{
+		"go.mod":      "module example.com/m\n\nrequire example.com/m/lib v1.0.0\n",
+		"lib/go.mod":  "module example.com/m/lib\n\nrequire example.com/m/base v1.0.0\n",
+		"lib/lib.go":  "package lib\n",
+		"base/go.mod": "module example.com/m/base\n",
+	})
+	app := &workspaceModule{dir: ".", path: "example.com/m", requires: []string{"example.com/m/lib"}}
+	lib := &workspaceModule{dir: "lib", path: "example.com/m/lib", requires: []string{"example.com/m/base"}}
+	base := &workspaceModule{dir: "base", path: "example.com/m/base"}
+	modules := []*workspaceModule{app, lib, base}
+
+	_, _, err := lockstepRequireSteps(modules, "v2.0.0")
+	if err == nil {
+		t.Error(ExpectError, "for a major version that moves the module paths")
+	}
+
+	updating, steps, err := lockstepRequireSteps(modules, "v1.0.1")
+	if err != nil {
+		t.Fatal(ExpectNilError, err)
+	}
+	if len(updating) != 2 || !updating["example.com/m/lib"] || !updating["example.com/m/base"] {
+		t.Error("incorrect result: expected lib and base to be updated, got", updating)
+	}
+	for _, step := range steps {
+		_, err := step(worktree)
+		if err != nil {
+			t.Fatal(ExpectNilError, err)
+		}
+	}
+	commit := testCommit(t, worktree, "chore: Updating version to v1.0.1", nil)
+
+	// The sums worked out from what was staged must be those of the commit the tags go on.
+	for dependent, dependency := range map[*workspaceModule]*workspaceModule{app: lib, lib: base} {
+		goSum, err := os.ReadFile(filepath.Join(gitDir, dependent.dir, "go.sum"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums, err := moduleSums(taggedModule{dir: dependency.dir, path: dependency.path, version: "v1.0.1", commit: commit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(goSum) != strings.Join(sums, "\n")+"\n" {
+			t.Errorf("incorrect result for %s: expected %q, got %q", dependent.dir, sums, goSum)
+		}
+	}
+}
+
+func TestPlanModuleReleaseOnReleaseLine(t *testing.T) {
+	worktree := useTestRepo(t, map[string]string{"lib/go.mod": "module example.com/lib\n"})
+	first := testCommit(t, worktree, "chore: init", nil)
+	second := testCommit(t, worktree, "feat: more", map[string]string{"lib/more.go": "package lib\n"})
+	for name, hash := range map[string]plumbing.Hash{"lib/v1.3.0": first, "lib/v1.3.1": second, "lib/v2.0.0": second} {
+		_, err := repo.CreateTag(name, hash, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() { viper.Set("branches", nil) })
+	viper.Set("branches", []map[string]string{{"name": "release/*", "line": "minor"}})
+	testCheckout(t, "release/1.3", second)
+	testCommit(t, worktree, "fix: lib", map[string]string{"lib/lib.go": "package lib\n"})
+	module := &workspaceModule{dir: "lib", path: "example.com/lib"}
+	cmd := &cobra.Command{}
+
+	release, err := planModuleRelease(cmd, module, nil)
+	if err != nil {
+		t.Error(ExpectNilError, err)
+	}
+	if release == nil || release.previous != "v1.3.1" || release.pvNext.String() != "1.3.2" {
+		t.Error("incorrect result: expected 1.3.2 after v1.3.1 on the 1.3 line, got", release)
+	}
+
+	cmd.Flags().Bool("minor", true, "")
+	_, err = planModuleRelease(cmd, module, nil)
+	if err == nil {
+		t.Error(ExpectError, "for a minor version on the 1.3 line")
+	}
+}